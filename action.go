@@ -5,5 +5,46 @@
 
 package xrpc
 
-// Action function type
+import (
+	"context"
+	"reflect"
+)
+
+// Action function type, the request is reused by transports
+// so it must not be retained after the action is finished
 type Action func(req Request) error
+
+// TypedAction converts typed function into the action.
+// Input data is binded from the request, result is sent as response
+// and the returned error is passed to the transport as is.
+// Binding errors are returned as CodeInvalidArgument errors.
+func TypedAction[In, Out any](fnk func(ctx context.Context, in In) (Out, error)) Action {
+	return func(req Request) error {
+		var in In
+		if err := req.Bind(&in); err != nil {
+			return NewError(CodeInvalidArgument, err.Error())
+		}
+		out, err := fnk(req.Context(), in)
+		if err != nil {
+			return err
+		}
+		return req.Send(out)
+	}
+}
+
+// Handle registers typed function as service action with action specific middlewares
+//
+// Example:
+//
+//	xrpc.Handle(svc, "hello", func(ctx context.Context, in *Input) (*Output, error) {
+//	  return &Output{Msg: "Hello " + in.Name}, nil
+//	})
+func Handle[In, Out any](svc Registrar, name string, fnk func(ctx context.Context, in In) (Out, error), middlewaries ...MiddlewareFunc) error {
+	if err := svc.Register(name, TypedAction(fnk), middlewaries...); err != nil {
+		return err
+	}
+	return svc.Describe(name, ActionInfo{
+		InputType:  reflect.TypeOf((*In)(nil)).Elem(),
+		OutputType: reflect.TypeOf((*Out)(nil)).Elem(),
+	})
+}
@@ -0,0 +1,79 @@
+//
+// @project geniusrabbit::xrpc 2017
+// @author Dmitry Ponomarev <demdxx@gmail.com> 2017
+//
+
+package xrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+type testRequest struct {
+	action string
+	data   []byte
+	resp   []byte
+	ctx    context.Context
+}
+
+func (r *testRequest) ID() []byte                          { return nil }
+func (r *testRequest) Action() []byte                      { return []byte(r.action) }
+func (r *testRequest) Timeout() time.Duration              { return 0 }
+func (r *testRequest) Header(name string) []byte           { return nil }
+func (r *testRequest) Headers() map[string][]byte          { return nil }
+func (r *testRequest) SetHeader(name string, value []byte) {}
+func (r *testRequest) Peer() Peer                          { return Peer{Transport: "test"} }
+func (r *testRequest) Context() context.Context            { return r.ctx }
+func (r *testRequest) SetContext(ctx context.Context)      { r.ctx = ctx }
+func (r *testRequest) Source() interface{}                 { return nil }
+
+func (r *testRequest) Bind(target interface{}) error {
+	return json.Unmarshal(r.data, target)
+}
+
+func (r *testRequest) Send(msg interface{}) (err error) {
+	r.resp, err = json.Marshal(msg)
+	return err
+}
+
+type testInput struct {
+	Name string `json:"name"`
+}
+
+type testOutput struct {
+	Msg string `json:"msg"`
+}
+
+func TestHandle(t *testing.T) {
+	var (
+		svc    = New()
+		errBad = errors.New("bad name")
+	)
+
+	err := Handle(svc, "hello", func(ctx context.Context, in *testInput) (*testOutput, error) {
+		if in.Name == "" {
+			return nil, errBad
+		}
+		return &testOutput{Msg: "Hello " + in.Name}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := &testRequest{action: "hello", data: []byte(`{"name":"test"}`), ctx: context.Background()}
+	if err := svc.Handle(req); err != nil {
+		t.Fatal(err)
+	}
+	if string(req.resp) != `{"msg":"Hello test"}` {
+		t.Errorf("invalid response: %s", req.resp)
+	}
+
+	req = &testRequest{action: "hello", data: []byte(`{}`), ctx: context.Background()}
+	if err := svc.Handle(req); err != errBad {
+		t.Errorf("expected error %v, got %v", errBad, err)
+	}
+}
@@ -0,0 +1,145 @@
+//
+// @project geniusrabbit::xrpc 2017
+// @author Dmitry Ponomarev <demdxx@gmail.com> 2017
+//
+
+package xrpc
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// TLSSchemePrefix of the address scheme for secure connections like `tls+tcp://`
+const TLSSchemePrefix = "tls+"
+
+// Address of the connection in the URL form:
+//
+//	host:port                         TCP address
+//	tcp://host:port                   also tcp4 and tcp6
+//	tls+tcp://host:port               TCP with TLS
+//	unix:///var/run/service.sock      absolute path of the unix socket
+//	unix://run/service.sock           relative path of the unix socket
+//	unix://@service                   abstract unix socket (Linux only)
+//
+// Query parameters of the address are kept as options of the transport.
+type Address struct {
+	// Network name accepted by the net package: tcp, tcp4, tcp6, unix, unixpacket
+	Network string
+
+	// Address of the host:port or path of the unix socket
+	Address string
+
+	// TLS is true for secure connections
+	TLS bool
+
+	// Query parameters of the address
+	Query url.Values
+}
+
+// ParseAddress of the connection
+func ParseAddress(addr string) (Address, error) {
+	var (
+		address             Address
+		scheme, rest, found = strings.Cut(addr, "://")
+		err                 error
+	)
+
+	if !found {
+		scheme, rest = "tcp", addr
+	}
+	rest, rawQuery, _ := strings.Cut(rest, "?")
+	if address.Query, err = url.ParseQuery(rawQuery); err != nil {
+		return address, fmt.Errorf("invalid address [%s] query: %w", addr, err)
+	}
+
+	if strings.HasPrefix(scheme, TLSSchemePrefix) {
+		scheme, address.TLS = scheme[len(TLSSchemePrefix):], true
+	}
+
+	switch scheme {
+	case "tcp", "tcp4", "tcp6":
+		address.Network, address.Address = scheme, strings.TrimSuffix(rest, "/")
+		if _, _, err = net.SplitHostPort(address.Address); err != nil {
+			return address, fmt.Errorf("invalid address [%s]: %w", addr, err)
+		}
+	case "unix", "unixpacket":
+		address.Network, address.Address = scheme, rest
+		if rest == "" || rest == "@" {
+			return address, fmt.Errorf("invalid address [%s]: empty socket path", addr)
+		}
+	default:
+		return address, fmt.Errorf("connection type [%s] not supported", scheme)
+	}
+	return address, nil
+}
+
+// Host name of the TCP address or the path of unix socket
+func (a Address) Host() string {
+	if host, _, err := net.SplitHostPort(a.Address); err == nil {
+		return host
+	}
+	return a.Address
+}
+
+// IsUnix returns true for unix socket address
+func (a Address) IsUnix() bool {
+	return a.Network == "unix" || a.Network == "unixpacket"
+}
+
+// Codec defined by the `codec` query parameter, nil if it's not defined
+func (a Address) Codec() (Codec, error) {
+	name := a.Query.Get("codec")
+	if name == "" {
+		return nil, nil
+	}
+	if codec := CodecByName(name); codec != nil {
+		return codec, nil
+	}
+	return nil, fmt.Errorf("codec [%s] is not registered", name)
+}
+
+// Int value of the query parameter or the default value if it's not defined
+func (a Address) Int(name string, def int) (int, error) {
+	value := a.Query.Get(name)
+	if value == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return def, fmt.Errorf("invalid [%s] option value: %w", name, err)
+	}
+	return n, nil
+}
+
+// Listen the address, stale unix socket file is removed before
+func (a Address) Listen() (net.Listener, error) {
+	if a.IsUnix() && !strings.HasPrefix(a.Address, "@") {
+		if info, err := os.Stat(a.Address); err == nil && info.Mode()&os.ModeSocket != 0 {
+			_ = os.Remove(a.Address)
+		}
+	}
+	return net.Listen(a.Network, a.Address)
+}
+
+// Dial the address without TLS handshake
+func (a Address) Dial() (net.Conn, error) {
+	return net.Dial(a.Network, a.Address)
+}
+
+// String returns address in the URL form
+func (a Address) String() string {
+	var scheme = a.Network
+	if a.TLS {
+		scheme = TLSSchemePrefix + scheme
+	}
+	s := scheme + "://" + a.Address
+	if len(a.Query) > 0 {
+		s += "?" + a.Query.Encode()
+	}
+	return s
+}
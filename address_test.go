@@ -0,0 +1,53 @@
+//
+// @project geniusrabbit::xrpc 2017
+// @author Dmitry Ponomarev <demdxx@gmail.com> 2017
+//
+
+package xrpc
+
+import "testing"
+
+func TestParseAddress(t *testing.T) {
+	tests := []struct {
+		addr    string
+		network string
+		address string
+		tls     bool
+	}{
+		{addr: "localhost:8080", network: "tcp", address: "localhost:8080"},
+		{addr: ":8080", network: "tcp", address: ":8080"},
+		{addr: "tcp://127.0.0.1:8080/", network: "tcp", address: "127.0.0.1:8080"},
+		{addr: "tcp4://127.0.0.1:8080", network: "tcp4", address: "127.0.0.1:8080"},
+		{addr: "tcp6://[::1]:8080", network: "tcp6", address: "[::1]:8080"},
+		{addr: "tls+tcp://example.com:443", network: "tcp", address: "example.com:443", tls: true},
+		{addr: "unix:///var/run/service.sock", network: "unix", address: "/var/run/service.sock"},
+		{addr: "unix://run/service.sock", network: "unix", address: "run/service.sock"},
+		{addr: "unix://./service.sock", network: "unix", address: "./service.sock"},
+		{addr: "unix://@service", network: "unix", address: "@service"},
+		{addr: "unix:///tmp/s.sock?mode=0664", network: "unix", address: "/tmp/s.sock"},
+	}
+	for _, test := range tests {
+		address, err := ParseAddress(test.addr)
+		if err != nil {
+			t.Errorf("[%s] unexpected error: %v", test.addr, err)
+			continue
+		}
+		if address.Network != test.network || address.Address != test.address || address.TLS != test.tls {
+			t.Errorf("[%s] invalid address: %+v", test.addr, address)
+		}
+	}
+
+	for _, addr := range []string{"udp://localhost:53", "tcp://localhost", "unix://", "unix://@", "localhost"} {
+		if _, err := ParseAddress(addr); err == nil {
+			t.Errorf("[%s] error expected", addr)
+		}
+	}
+
+	address, _ := ParseAddress("tls+tcp://example.com:443?compress=snappy")
+	if address.Query.Get("compress") != "snappy" || address.Host() != "example.com" {
+		t.Errorf("invalid address options: %+v", address)
+	}
+	if s := address.String(); s != "tls+tcp://example.com:443?compress=snappy" {
+		t.Errorf("invalid address string: %s", s)
+	}
+}
@@ -0,0 +1,78 @@
+//
+// @project geniusrabbit::xrpc 2017
+// @author Dmitry Ponomarev <demdxx@gmail.com> 2017
+//
+
+package xrpc
+
+import (
+	"context"
+	"time"
+)
+
+// CallOption modifies the message before sending
+type CallOption func(msg *Message)
+
+// WithID of the request
+func WithID(id string) CallOption {
+	return func(msg *Message) {
+		msg.ID = id
+	}
+}
+
+// WithTimeout of the request processing
+func WithTimeout(timeout time.Duration) CallOption {
+	return func(msg *Message) {
+		msg.Timeout = timeout
+	}
+}
+
+// WithHeader appends header value to the message
+func WithHeader(key string, value interface{}) CallOption {
+	return func(msg *Message) {
+		if msg.Headers == nil {
+			msg.Headers = map[string]interface{}{}
+		}
+		msg.Headers[key] = value
+	}
+}
+
+// WithHeaders appends all header values to the message
+func WithHeaders(headers map[string]interface{}) CallOption {
+	return func(msg *Message) {
+		for key, value := range headers {
+			WithHeader(key, value)(msg)
+		}
+	}
+}
+
+// WithCodec of the message data encoding
+func WithCodec(codec Codec) CallOption {
+	return func(msg *Message) {
+		msg.Codec = codec
+	}
+}
+
+// Call action of the service by client and bind the result into typed output
+//
+// Example:
+//
+//	out, err := xrpc.Call[*Input, *Output](ctx, client, "hello", &Input{Name: "test"},
+//	  xrpc.WithTimeout(100*time.Millisecond))
+func Call[In, Out any](ctx context.Context, client Client, action string, in In, opts ...CallOption) (out Out, err error) {
+	var msg = Message{Action: action, Data: in}
+
+	for _, opt := range opts {
+		opt(&msg)
+	}
+
+	resp := client.SendContext(ctx, msg)
+	defer resp.Release()
+
+	if err = resp.Error(); err != nil {
+		return out, err
+	}
+
+	err = resp.Bind(&out)
+	return out, err
+}
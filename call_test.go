@@ -0,0 +1,96 @@
+//
+// @project geniusrabbit::xrpc 2017
+// @author Dmitry Ponomarev <demdxx@gmail.com> 2017
+//
+
+package xrpc
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+type testResponse struct {
+	data []byte
+	err  error
+}
+
+func (r *testResponse) Source() interface{}        { return nil }
+func (r *testResponse) Header(name string) []byte  { return nil }
+func (r *testResponse) Headers() map[string][]byte { return nil }
+func (r *testResponse) Error() error               { return r.err }
+func (r *testResponse) Release()                   {}
+
+func (r *testResponse) Bind(target interface{}) error {
+	if r.err != nil {
+		return r.err
+	}
+	return json.Unmarshal(r.data, target)
+}
+
+// testClient sends messages directly to the service
+type testClient struct {
+	svc  Service
+	last Message
+}
+
+func (c *testClient) Send(msg Message) Response {
+	return c.SendContext(context.Background(), msg)
+}
+
+func (c *testClient) SendContext(ctx context.Context, msg Message) Response {
+	if err := ctx.Err(); err != nil {
+		return &testResponse{err: err}
+	}
+	msg.Timeout = ContextTimeout(ctx, msg.Timeout)
+	c.last = msg
+	data, err := json.Marshal(msg.Data)
+	if err != nil {
+		return &testResponse{err: err}
+	}
+	req := &testRequest{action: msg.Action, data: data, ctx: context.Background()}
+	if err = c.svc.Handle(req); err != nil {
+		return &testResponse{err: err}
+	}
+	return &testResponse{data: req.resp}
+}
+
+func TestCall(t *testing.T) {
+	var (
+		svc    = New()
+		client = &testClient{svc: svc}
+	)
+
+	Handle(svc, "hello", func(ctx context.Context, in *testInput) (*testOutput, error) {
+		return &testOutput{Msg: "Hello " + in.Name}, nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	out, err := Call[*testInput, *testOutput](ctx, client, "hello", &testInput{Name: "test"},
+		WithID("id1"), WithTimeout(time.Minute), WithHeader("key", "value"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Msg != "Hello test" {
+		t.Errorf("invalid response: %s", out.Msg)
+	}
+	if client.last.ID != "id1" || client.last.Headers["key"] != "value" {
+		t.Errorf("invalid message options: %v", client.last)
+	}
+	if client.last.Timeout <= 0 || client.last.Timeout > time.Second {
+		t.Errorf("timeout must be limited by context deadline: %s", client.last.Timeout)
+	}
+
+	if _, err = Call[*testInput, *testOutput](ctx, client, "unknown", nil); err != ErrActionNotFound {
+		t.Errorf("expected error %v, got %v", ErrActionNotFound, err)
+	}
+
+	cancel()
+	if _, err = Call[*testInput, *testOutput](ctx, client, "hello", nil); err != context.Canceled {
+		t.Errorf("expected error %v, got %v", context.Canceled, err)
+	}
+}
@@ -5,8 +5,34 @@
 
 package xrpc
 
+import (
+	"context"
+	"time"
+)
+
 // Client interface describer
 type Client interface {
 	// Send message to service
 	Send(msg Message) Response
+
+	// SendContext message to service with cancellation and deadline of the context.
+	// The effective timeout is the minimum of the message timeout and the context deadline.
+	SendContext(ctx context.Context, msg Message) Response
+}
+
+// ContextTimeout returns the minimum of the timeout and the context deadline.
+// If the context has no deadline the timeout returns as is, where
+// zero value means no timeout.
+func ContextTimeout(ctx context.Context, timeout time.Duration) time.Duration {
+	if deadline, ok := ctx.Deadline(); ok {
+		if ctxTimeout := time.Until(deadline); timeout <= 0 || ctxTimeout < timeout {
+			if ctxTimeout <= 0 {
+				// Deadline already passed, the minimal positive timeout is returned
+				// to prevent processing without any time limit
+				return time.Nanosecond
+			}
+			return ctxTimeout
+		}
+	}
+	return timeout
 }
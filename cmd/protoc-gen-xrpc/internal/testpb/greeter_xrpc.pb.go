@@ -0,0 +1,43 @@
+// Code generated by protoc-gen-xrpc. DO NOT EDIT.
+// source: greeter.proto
+
+package testpb
+
+import (
+	context "context"
+	xrpc "github.com/geniusrabbit/xrpc"
+	protobuf "github.com/geniusrabbit/xrpc/codec/protobuf"
+)
+
+// GreeterServer is the server API for Greeter service.
+type GreeterServer interface {
+	// Watch skipped: streaming methods are not supported
+	Hello(context.Context, *HelloRequest) (*HelloResponse, error)
+}
+
+// RegisterGreeterServer actions of the service implementation
+func RegisterGreeterServer(svc xrpc.Service, srv GreeterServer) error {
+	if err := xrpc.Handle(svc, "xrpc.test.Greeter/Hello", srv.Hello); err != nil {
+		return err
+	}
+	return nil
+}
+
+// GreeterClient is the client API for Greeter service.
+type GreeterClient interface {
+	Hello(ctx context.Context, in *HelloRequest, opts ...xrpc.CallOption) (*HelloResponse, error)
+}
+
+type greeterClient struct {
+	client xrpc.Client
+}
+
+// NewGreeterClient wraps xrpc client with typed API
+func NewGreeterClient(client xrpc.Client) GreeterClient {
+	return &greeterClient{client: client}
+}
+
+func (c *greeterClient) Hello(ctx context.Context, in *HelloRequest, opts ...xrpc.CallOption) (*HelloResponse, error) {
+	opts = append([]xrpc.CallOption{xrpc.WithCodec(protobuf.Codec)}, opts...)
+	return xrpc.Call[*HelloRequest, *HelloResponse](ctx, c.client, "xrpc.test.Greeter/Hello", in, opts...)
+}
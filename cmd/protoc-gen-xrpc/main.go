@@ -0,0 +1,35 @@
+//
+// @project geniusrabbit::xrpc 2017
+// @author Dmitry Ponomarev <demdxx@gmail.com> 2017
+//
+
+// protoc-gen-xrpc is a plugin for the Google protocol buffer compiler
+// which generates xrpc service interfaces, registration functions
+// and typed clients.
+//
+// Install:
+//
+//	go install github.com/geniusrabbit/xrpc/cmd/protoc-gen-xrpc
+//
+// Usage:
+//
+//	protoc --go_out=. --xrpc_out=. service.proto
+//
+// Each RPC method is registered as action named by the full method
+// name like `package.Service/Method` and encoded by protobuf codec.
+package main
+
+import (
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+func main() {
+	protogen.Options{}.Run(func(gen *protogen.Plugin) error {
+		for _, file := range gen.Files {
+			if file.Generate {
+				generateFile(gen, file)
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,129 @@
+//
+// @project geniusrabbit::xrpc 2017
+// @author Dmitry Ponomarev <demdxx@gmail.com> 2017
+//
+
+package main
+
+import (
+	"unicode"
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+const (
+	contextPackage  = protogen.GoImportPath("context")
+	xrpcPackage     = protogen.GoImportPath("github.com/geniusrabbit/xrpc")
+	protobufPackage = protogen.GoImportPath("github.com/geniusrabbit/xrpc/codec/protobuf")
+)
+
+// generateFile with xrpc services of the proto file
+func generateFile(gen *protogen.Plugin, file *protogen.File) *protogen.GeneratedFile {
+	if len(file.Services) == 0 {
+		return nil
+	}
+
+	g := gen.NewGeneratedFile(file.GeneratedFilenamePrefix+"_xrpc.pb.go", file.GoImportPath)
+	g.P("// Code generated by protoc-gen-xrpc. DO NOT EDIT.")
+	g.P("// source: ", file.Desc.Path())
+	g.P()
+	g.P("package ", file.GoPackageName)
+	g.P()
+
+	for _, service := range file.Services {
+		generateServer(g, service)
+		generateClient(g, service)
+	}
+	return g
+}
+
+func generateServer(g *protogen.GeneratedFile, service *protogen.Service) {
+	serverName := service.GoName + "Server"
+
+	g.P("// ", serverName, " is the server API for ", service.GoName, " service.")
+	g.P("type ", serverName, " interface {")
+	for _, method := range unaryMethods(g, service) {
+		g.P(method.Comments.Leading, method.GoName, "(", contextPackage.Ident("Context"), ", *", method.Input.GoIdent,
+			") (*", method.Output.GoIdent, ", error)")
+	}
+	g.P("}")
+	g.P()
+
+	g.P("// Register", serverName, " actions of the service implementation")
+	g.P("func Register", serverName, "(svc ", xrpcPackage.Ident("Service"), ", srv ", serverName, ") error {")
+	for _, method := range unaryMethods(nil, service) {
+		g.P("if err := ", xrpcPackage.Ident("Handle"), "(svc, ", actionName(method), ", srv.", method.GoName, "); err != nil {")
+		g.P("return err")
+		g.P("}")
+	}
+	g.P("return nil")
+	g.P("}")
+	g.P()
+}
+
+func generateClient(g *protogen.GeneratedFile, service *protogen.Service) {
+	var (
+		clientName = service.GoName + "Client"
+		structName = unexport(clientName)
+	)
+
+	g.P("// ", clientName, " is the client API for ", service.GoName, " service.")
+	g.P("type ", clientName, " interface {")
+	for _, method := range unaryMethods(nil, service) {
+		g.P(method.Comments.Leading, method.GoName, "(ctx ", contextPackage.Ident("Context"), ", in *", method.Input.GoIdent,
+			", opts ...", xrpcPackage.Ident("CallOption"), ") (*", method.Output.GoIdent, ", error)")
+	}
+	g.P("}")
+	g.P()
+
+	g.P("type ", structName, " struct {")
+	g.P("client ", xrpcPackage.Ident("Client"))
+	g.P("}")
+	g.P()
+
+	g.P("// New", clientName, " wraps xrpc client with typed API")
+	g.P("func New", clientName, "(client ", xrpcPackage.Ident("Client"), ") ", clientName, " {")
+	g.P("return &", structName, "{client: client}")
+	g.P("}")
+	g.P()
+
+	for _, method := range unaryMethods(nil, service) {
+		g.P("func (c *", structName, ") ", method.GoName, "(ctx ", contextPackage.Ident("Context"), ", in *", method.Input.GoIdent,
+			", opts ...", xrpcPackage.Ident("CallOption"), ") (*", method.Output.GoIdent, ", error) {")
+		g.P("opts = append([]", xrpcPackage.Ident("CallOption"), "{", xrpcPackage.Ident("WithCodec"), "(", protobufPackage.Ident("Codec"), ")}, opts...)")
+		g.P("return ", xrpcPackage.Ident("Call"), "[*", method.Input.GoIdent, ", *", method.Output.GoIdent, "](ctx, c.client, ",
+			actionName(method), ", in, opts...)")
+		g.P("}")
+		g.P()
+	}
+}
+
+// unaryMethods of the service, streaming methods are not supported by xrpc
+// so they are skipped with the comment if generated file is defined
+func unaryMethods(g *protogen.GeneratedFile, service *protogen.Service) (methods []*protogen.Method) {
+	for _, method := range service.Methods {
+		if method.Desc.IsStreamingClient() || method.Desc.IsStreamingServer() {
+			if g != nil {
+				g.P("// ", method.GoName, " skipped: streaming methods are not supported")
+			}
+			continue
+		}
+		methods = append(methods, method)
+	}
+	return methods
+}
+
+// actionName returns quoted full method name like `package.Service/Method`
+func actionName(method *protogen.Method) string {
+	return `"` + string(method.Parent.Desc.FullName()) + "/" + string(method.Desc.Name()) + `"`
+}
+
+// unexport the identifier by the lower case of the first letter
+func unexport(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	if size == 0 {
+		return s
+	}
+	return string(unicode.ToLower(r)) + s[size:]
+}
@@ -0,0 +1,79 @@
+//
+// @project geniusrabbit::xrpc 2017
+// @author Dmitry Ponomarev <demdxx@gmail.com> 2017
+//
+
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/geniusrabbit/xrpc/cmd/protoc-gen-xrpc/internal/testpb"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+var update = flag.Bool("update", false, "update generated files of the testpb package")
+
+// TestGenerateFile compares the output with the file of the testpb package
+// which is compiled along with the repository
+func TestGenerateFile(t *testing.T) {
+	gen, err := protogen.Options{}.New(&pluginpb.CodeGeneratorRequest{
+		FileToGenerate: []string{testpb.File_greeter_proto.Path()},
+		Parameter:      proto.String("paths=source_relative"),
+		ProtoFile: []*descriptorpb.FileDescriptorProto{
+			protodesc.ToFileDescriptorProto(testpb.File_greeter_proto),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range gen.Files {
+		if file.Generate {
+			generateFile(gen, file)
+		}
+	}
+
+	resp := gen.Response()
+	if resp.Error != nil {
+		t.Fatal(resp.GetError())
+	}
+	if len(resp.File) != 1 || resp.File[0].GetName() != "greeter_xrpc.pb.go" {
+		t.Fatalf("invalid generated files: %v", resp.File)
+	}
+
+	golden := filepath.Join("internal", "testpb", resp.File[0].GetName())
+	if *update {
+		if err = os.WriteFile(golden, []byte(resp.File[0].GetContent()), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(expected) != resp.File[0].GetContent() {
+		t.Errorf("generated file differs from [%s], run the test with -update flag:\n%s", golden, resp.File[0].GetContent())
+	}
+}
+
+func TestUnexport(t *testing.T) {
+	tests := map[string]string{
+		"":              "",
+		"GreeterClient": "greeterClient",
+		"greeter":       "greeter",
+		"_Greeter":      "_Greeter",
+		"ÜberClient":    "überClient",
+	}
+	for name, expected := range tests {
+		if res := unexport(name); res != expected {
+			t.Errorf("invalid unexport of [%s]: %s", name, res)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+//
+// @project geniusrabbit::xrpc 2017
+// @author Dmitry Ponomarev <demdxx@gmail.com> 2017
+//
+
+package xrpc
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"sync"
+)
+
+// Codec describes encoding of the message data
+type Codec interface {
+	// Name of the codec which is transferred by wire, like: json, msgpack, gob
+	Name() string
+
+	// Marshal value into bytes
+	Marshal(v interface{}) ([]byte, error)
+
+	// Unmarshal bytes into the target value
+	Unmarshal(data []byte, target interface{}) error
+}
+
+// Default codecs
+var (
+	JSONCodec Codec = jsonCodec{}
+	GobCodec  Codec = gobCodec{}
+
+	// DefaultCodec used if no any other codec defined
+	DefaultCodec = JSONCodec
+)
+
+var codecRegistry struct {
+	mx     sync.RWMutex
+	codecs map[string]Codec
+}
+
+func init() {
+	RegisterCodec(JSONCodec)
+	RegisterCodec(GobCodec)
+}
+
+// RegisterCodec to make it available for decoding by name
+func RegisterCodec(codec Codec) {
+	codecRegistry.mx.Lock()
+	defer codecRegistry.mx.Unlock()
+	if codecRegistry.codecs == nil {
+		codecRegistry.codecs = map[string]Codec{}
+	}
+	codecRegistry.codecs[codec.Name()] = codec
+}
+
+// CodecByName returns registered codec or nil.
+// Empty name returns DefaultCodec.
+func CodecByName(name string) Codec {
+	if name == "" {
+		return DefaultCodec
+	}
+	codecRegistry.mx.RLock()
+	defer codecRegistry.mx.RUnlock()
+	return codecRegistry.codecs[name]
+}
+
+// CodecOrDefault returns first not nil codec or DefaultCodec
+func CodecOrDefault(codecs ...Codec) Codec {
+	for _, codec := range codecs {
+		if codec != nil {
+			return codec
+		}
+	}
+	return DefaultCodec
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string {
+	return "json"
+}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, target interface{}) error {
+	return json.Unmarshal(data, target)
+}
+
+type gobCodec struct{}
+
+func (gobCodec) Name() string {
+	return "gob"
+}
+
+func (gobCodec) Marshal(v interface{}) ([]byte, error) {
+	var buff bytes.Buffer
+	if err := gob.NewEncoder(&buff).Encode(v); err != nil {
+		return nil, err
+	}
+	return buff.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, target interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(target)
+}
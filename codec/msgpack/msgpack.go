@@ -0,0 +1,34 @@
+//
+// @project geniusrabbit::xrpc 2017
+// @author Dmitry Ponomarev <demdxx@gmail.com> 2017
+//
+
+// Package msgpack implements MessagePack codec for xrpc messages.
+// The codec is registered on import.
+package msgpack
+
+import (
+	"github.com/geniusrabbit/xrpc"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Codec of MessagePack encoding
+var Codec xrpc.Codec = codec{}
+
+func init() {
+	xrpc.RegisterCodec(Codec)
+}
+
+type codec struct{}
+
+func (codec) Name() string {
+	return "msgpack"
+}
+
+func (codec) Marshal(v interface{}) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+func (codec) Unmarshal(data []byte, target interface{}) error {
+	return msgpack.Unmarshal(data, target)
+}
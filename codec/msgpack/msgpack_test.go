@@ -0,0 +1,42 @@
+//
+// @project geniusrabbit::xrpc 2017
+// @author Dmitry Ponomarev <demdxx@gmail.com> 2017
+//
+
+package msgpack
+
+import (
+	"testing"
+
+	"github.com/geniusrabbit/xrpc"
+)
+
+type testMessage struct {
+	Name  string            `msgpack:"name"`
+	Count int               `msgpack:"count"`
+	Tags  map[string]string `msgpack:"tags"`
+}
+
+func TestCodec(t *testing.T) {
+	if xrpc.CodecByName("msgpack") != Codec {
+		t.Fatal("codec must be registered on import")
+	}
+
+	var (
+		in        = testMessage{Name: "test", Count: 2, Tags: map[string]string{"a": "b"}}
+		out       testMessage
+		data, err = Codec.Marshal(&in)
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = Codec.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Name != in.Name || out.Count != in.Count || out.Tags["a"] != "b" {
+		t.Errorf("invalid round trip: %+v", out)
+	}
+	if err = Codec.Unmarshal([]byte{0xc1}, &out); err == nil {
+		t.Error("invalid data must fail")
+	}
+}
@@ -0,0 +1,59 @@
+//
+// @project geniusrabbit::xrpc 2017
+// @author Dmitry Ponomarev <demdxx@gmail.com> 2017
+//
+
+// Package protobuf implements Protocol Buffers codec for xrpc messages.
+// The codec is registered on import.
+package protobuf
+
+import (
+	"errors"
+	"reflect"
+
+	"github.com/geniusrabbit/xrpc"
+	"google.golang.org/protobuf/proto"
+)
+
+// ErrNotProtoMessage if value can't be encoded by protobuf
+var ErrNotProtoMessage = errors.New("Value is not a protobuf message")
+
+// Codec of Protocol Buffers encoding
+var Codec xrpc.Codec = codec{}
+
+func init() {
+	xrpc.RegisterCodec(Codec)
+}
+
+type codec struct{}
+
+func (codec) Name() string {
+	return "protobuf"
+}
+
+func (codec) Marshal(v interface{}) ([]byte, error) {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return nil, ErrNotProtoMessage
+	}
+	return proto.Marshal(msg)
+}
+
+// Unmarshal data into the message or into the pointer to the message pointer,
+// in the last case the message is allocated if it's nil
+func (codec) Unmarshal(data []byte, target interface{}) error {
+	msg, ok := target.(proto.Message)
+	if !ok {
+		val := reflect.ValueOf(target)
+		if val.Kind() != reflect.Ptr || val.IsNil() || val.Elem().Kind() != reflect.Ptr {
+			return ErrNotProtoMessage
+		}
+		if val.Elem().IsNil() {
+			val.Elem().Set(reflect.New(val.Elem().Type().Elem()))
+		}
+		if msg, ok = val.Elem().Interface().(proto.Message); !ok {
+			return ErrNotProtoMessage
+		}
+	}
+	return proto.Unmarshal(data, msg)
+}
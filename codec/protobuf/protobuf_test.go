@@ -0,0 +1,58 @@
+//
+// @project geniusrabbit::xrpc 2017
+// @author Dmitry Ponomarev <demdxx@gmail.com> 2017
+//
+
+package protobuf
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/geniusrabbit/xrpc"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestCodec(t *testing.T) {
+	if xrpc.CodecByName("protobuf") != Codec {
+		t.Fatal("codec must be registered on import")
+	}
+
+	data, err := Codec.Marshal(wrapperspb.String("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out wrapperspb.StringValue
+	if err = Codec.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.GetValue() != "test" {
+		t.Errorf("invalid round trip: %s", out.GetValue())
+	}
+
+	// Message is allocated by the pointer to the message pointer
+	var ptr *wrapperspb.StringValue
+	if err = Codec.Unmarshal(data, &ptr); err != nil {
+		t.Fatal(err)
+	}
+	if ptr.GetValue() != "test" {
+		t.Errorf("invalid round trip of the message pointer: %s", ptr.GetValue())
+	}
+}
+
+func TestCodecNotProtoMessage(t *testing.T) {
+	var (
+		str   string
+		strp  *string
+		value = struct{ Name string }{Name: "test"}
+	)
+	if _, err := Codec.Marshal(value); !errors.Is(err, ErrNotProtoMessage) {
+		t.Errorf("marshal of non protobuf value must fail: %v", err)
+	}
+	for _, target := range []interface{}{nil, value, &value, &str, &strp} {
+		if err := Codec.Unmarshal(nil, target); !errors.Is(err, ErrNotProtoMessage) {
+			t.Errorf("unmarshal into %T must fail: %v", target, err)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+//
+// @project geniusrabbit::xrpc 2017
+// @author Dmitry Ponomarev <demdxx@gmail.com> 2017
+//
+
+package xrpc
+
+import (
+	"testing"
+)
+
+func TestCodecs(t *testing.T) {
+	for _, name := range []string{"", "json", "gob"} {
+		codec := CodecByName(name)
+		if codec == nil {
+			t.Errorf("codec [%s] must be registered", name)
+			continue
+		}
+
+		var (
+			out       testOutput
+			data, err = codec.Marshal(&testOutput{Msg: "test"})
+		)
+		if err != nil {
+			t.Errorf("codec [%s] marshal: %v", name, err)
+			continue
+		}
+		if err = codec.Unmarshal(data, &out); err != nil || out.Msg != "test" {
+			t.Errorf("codec [%s] unmarshal: %v %v", name, out, err)
+		}
+	}
+
+	if CodecByName("unknown") != nil {
+		t.Errorf("unknown codec must be nil")
+	}
+}
@@ -0,0 +1,79 @@
+//
+// @project geniusrabbit::xrpc 2017
+// @author Dmitry Ponomarev <demdxx@gmail.com> 2017
+//
+
+package xrpc
+
+import (
+	"reflect"
+	"sort"
+)
+
+// DescribeAction is the reserved name of the built-in action
+// which returns the list of service actions
+const DescribeAction = "_describe"
+
+// ActionInfo describes registered action
+type ActionInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Input       string `json:"input,omitempty"`
+	Output      string `json:"output,omitempty"`
+	Deprecated  string `json:"deprecated,omitempty"`
+
+	// InputType and OutputType are defined for typed actions
+	InputType  reflect.Type `json:"-"`
+	OutputType reflect.Type `json:"-"`
+}
+
+// IsDeprecated returns true if the action deprecation message is defined
+func (info *ActionInfo) IsDeprecated() bool {
+	return info.Deprecated != ""
+}
+
+// merge not empty fields of another info
+func (info *ActionInfo) merge(info2 ActionInfo) {
+	if info2.Description != "" {
+		info.Description = info2.Description
+	}
+	if info2.Deprecated != "" {
+		info.Deprecated = info2.Deprecated
+	}
+	if info2.InputType != nil {
+		info.InputType = info2.InputType
+	}
+	if info2.OutputType != nil {
+		info.OutputType = info2.OutputType
+	}
+	if info.InputType != nil {
+		info.Input = info.InputType.String()
+	} else if info2.Input != "" {
+		info.Input = info2.Input
+	}
+	if info.OutputType != nil {
+		info.Output = info.OutputType.String()
+	} else if info2.Output != "" {
+		info.Output = info2.Output
+	}
+}
+
+// RegisterDescribe registers built-in action DescribeAction
+// which returns list of the service actions
+func RegisterDescribe(svc Service) error {
+	if err := svc.Register(DescribeAction, func(req Request) error {
+		return req.Send(svc.Actions())
+	}); err != nil {
+		return err
+	}
+	return svc.Describe(DescribeAction, ActionInfo{
+		Description: "List of the service actions",
+		OutputType:  reflect.TypeOf([]ActionInfo{}),
+	})
+}
+
+func sortActions(actions []ActionInfo) {
+	sort.Slice(actions, func(i, j int) bool {
+		return actions[i].Name < actions[j].Name
+	})
+}
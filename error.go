@@ -0,0 +1,193 @@
+//
+// @project geniusrabbit::xrpc 2017
+// @author Dmitry Ponomarev <demdxx@gmail.com> 2017
+//
+
+package xrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+)
+
+// ErrorCode describes the class of the error
+type ErrorCode int
+
+// Error codes list
+const (
+	CodeUnknown ErrorCode = iota
+	CodeInternal
+	CodeNotFound
+	CodeInvalidArgument
+	CodeDeadlineExceeded
+	CodeUnavailable
+	CodeOverloaded
+)
+
+var errorCodeNames = map[ErrorCode]string{
+	CodeUnknown:          "unknown",
+	CodeInternal:         "internal",
+	CodeNotFound:         "not_found",
+	CodeInvalidArgument:  "invalid_argument",
+	CodeDeadlineExceeded: "deadline_exceeded",
+	CodeUnavailable:      "unavailable",
+	CodeOverloaded:       "overloaded",
+}
+
+func (c ErrorCode) String() string {
+	if name, ok := errorCodeNames[c]; ok {
+		return name
+	}
+	return errorCodeNames[CodeUnknown]
+}
+
+// Error object which is transferred by wire between services
+type Error struct {
+	Message string          `json:"error"`
+	Code    ErrorCode       `json:"code,omitempty"`
+	Details json.RawMessage `json:"details,omitempty"`
+}
+
+// NewError object with code
+func NewError(code ErrorCode, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+// WithDetails returns copy of the error with encoded details payload
+func (e *Error) WithDetails(details interface{}) (*Error, error) {
+	data, err := json.Marshal(details)
+	if err != nil {
+		return nil, err
+	}
+	return &Error{Code: e.Code, Message: e.Message, Details: data}, nil
+}
+
+// BindDetails payload to object or structure
+func (e *Error) BindDetails(target interface{}) error {
+	if len(e.Details) < 1 {
+		return nil
+	}
+	return json.Unmarshal(e.Details, target)
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Is compares errors by code and message
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code && t.Message == e.Message
+}
+
+// Unwrap returns registered sentinel error with the same code and message
+func (e *Error) Unwrap() error {
+	return lookupError(e.Code, e.Message)
+}
+
+type registeredError struct {
+	code ErrorCode
+	err  error
+}
+
+var errorRegistry struct {
+	mx     sync.RWMutex
+	errors []registeredError
+}
+
+func init() {
+	RegisterError(CodeNotFound, ErrActionNotFound)
+	RegisterError(CodeDeadlineExceeded, ErrDeadlineExceeded)
+	RegisterError(CodeUnavailable, ErrUnavailable)
+	RegisterError(CodeUnavailable, ErrServerClosed)
+	RegisterError(CodeOverloaded, ErrOverloaded)
+	RegisterError(CodeInternal, ErrInternal)
+}
+
+// RegisterError as sentinel of the code so the error returned by the server
+// will be restored on the client side and errors.Is works with it
+func RegisterError(code ErrorCode, err error) {
+	errorRegistry.mx.Lock()
+	defer errorRegistry.mx.Unlock()
+	errorRegistry.errors = append(errorRegistry.errors, registeredError{code: code, err: err})
+}
+
+func lookupError(code ErrorCode, message string) error {
+	errorRegistry.mx.RLock()
+	defer errorRegistry.mx.RUnlock()
+	for _, reg := range errorRegistry.errors {
+		// Code could be empty in case of old-style error messages
+		if (code == CodeUnknown || code == reg.code) && strings.EqualFold(reg.err.Error(), message) {
+			return reg.err
+		}
+	}
+	return nil
+}
+
+func lookupErrorCode(err error) ErrorCode {
+	errorRegistry.mx.RLock()
+	defer errorRegistry.mx.RUnlock()
+	for _, reg := range errorRegistry.errors {
+		if errors.Is(err, reg.err) {
+			return reg.code
+		}
+	}
+	return CodeUnknown
+}
+
+// ErrorCodeOf returns the code of any error
+func ErrorCodeOf(err error) ErrorCode {
+	var e *Error
+	switch {
+	case err == nil:
+		return CodeUnknown
+	case errors.As(err, &e):
+		return e.Code
+	case errors.Is(err, context.DeadlineExceeded):
+		return CodeDeadlineExceeded
+	}
+	if code := lookupErrorCode(err); code != CodeUnknown {
+		return code
+	}
+	return CodeInternal
+}
+
+// WireError converts any error into the error object transferred by wire
+func WireError(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = ErrDeadlineExceeded
+	}
+	return &Error{Code: ErrorCodeOf(err), Message: err.Error()}
+}
+
+// MarshalError into the wire representation
+func MarshalError(err error) []byte {
+	data, jerr := json.Marshal(WireError(err))
+	if jerr != nil {
+		data, _ = json.Marshal(&Error{Code: CodeInternal, Message: err.Error()})
+	}
+	return data
+}
+
+// UnmarshalError from the wire representation.
+// Returns registered sentinel error if it matches to the code and message,
+// *Error object in other cases or nil if data contains no error.
+func UnmarshalError(data []byte) error {
+	var e Error
+	if err := json.Unmarshal(data, &e); err != nil || e.Message == "" {
+		return nil
+	}
+	if len(e.Details) < 1 {
+		if err := lookupError(e.Code, e.Message); err != nil {
+			return err
+		}
+	}
+	return &e
+}
@@ -0,0 +1,72 @@
+//
+// @project geniusrabbit::xrpc 2017
+// @author Dmitry Ponomarev <demdxx@gmail.com> 2017
+//
+
+package xrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestErrorRoundTrip(t *testing.T) {
+	errCustom := errors.New("Custom error")
+	RegisterError(CodeInvalidArgument, errCustom)
+
+	tests := []struct {
+		err    error
+		target error
+		code   ErrorCode
+	}{
+		{err: ErrActionNotFound, target: ErrActionNotFound, code: CodeNotFound},
+		{err: ErrDeadlineExceeded, target: ErrDeadlineExceeded, code: CodeDeadlineExceeded},
+		{err: context.DeadlineExceeded, target: ErrDeadlineExceeded, code: CodeDeadlineExceeded},
+		{err: ErrOverloaded, target: ErrOverloaded, code: CodeOverloaded},
+		{err: errCustom, target: errCustom, code: CodeInvalidArgument},
+		{err: NewError(CodeUnavailable, "down"), target: NewError(CodeUnavailable, "down"), code: CodeUnavailable},
+		{err: fmt.Errorf("some error"), code: CodeInternal},
+	}
+
+	for _, test := range tests {
+		err := UnmarshalError(MarshalError(test.err))
+		if err == nil {
+			t.Errorf("error [%v] must be decoded", test.err)
+			continue
+		}
+		if test.target != nil && !errors.Is(err, test.target) {
+			t.Errorf("decoded error [%v] must be [%v]", err, test.target)
+		}
+		if code := ErrorCodeOf(err); code != test.code {
+			t.Errorf("decoded error [%v] code must be %s, got %s", err, test.code, code)
+		}
+	}
+}
+
+func TestErrorDetails(t *testing.T) {
+	err, _ := NewError(CodeNotFound, ErrActionNotFound.Error()).WithDetails(map[string]string{"action": "test"})
+	decoded := UnmarshalError(MarshalError(err))
+	if !errors.Is(decoded, ErrActionNotFound) {
+		t.Errorf("decoded error [%v] must be [%v]", decoded, ErrActionNotFound)
+	}
+
+	var (
+		details map[string]string
+		e       *Error
+	)
+	if !errors.As(decoded, &e) {
+		t.Fatalf("decoded error must be *Error")
+	}
+	if err := e.BindDetails(&details); err != nil || details["action"] != "test" {
+		t.Errorf("invalid details: %v %v", details, err)
+	}
+
+	if UnmarshalError([]byte(`{"result":"ok"}`)) != nil {
+		t.Errorf("response without error must not be decoded as error")
+	}
+	if UnmarshalError([]byte(`{"error":"action not found"}`)) != ErrActionNotFound {
+		t.Errorf("old style error must be decoded as sentinel")
+	}
+}
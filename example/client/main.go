@@ -3,18 +3,19 @@ package main
 import (
 	"flag"
 	"fmt"
+	"log"
 	"reflect"
 	"strconv"
 	"time"
 
 	"github.com/geniusrabbit/xrpc"
-	"github.com/geniusrabbit/xrpc/fasthttp"
-	"github.com/geniusrabbit/xrpc/fastrpc"
+	_ "github.com/geniusrabbit/xrpc/fasthttp"
+	_ "github.com/geniusrabbit/xrpc/fastrpc"
 )
 
 var (
-	flagType    = flag.String("type", "http", "Client type: http, fastrpc, fastprcmulty")
-	flagConnect = flag.String("connect", "0.0.0.0:20202", "Connect address")
+	flagConnect = flag.String("connect", "http://0.0.0.0:20202",
+		"Connect URL: http://host:port, fastrpc://host:port or fastrpc://host:port?clients=10")
 )
 
 type tmsg struct {
@@ -24,16 +25,13 @@ type tmsg struct {
 func main() {
 	flag.Parse()
 
-	fmt.Println("Run", *flagType, *flagConnect)
+	fmt.Println("Run", *flagConnect)
 
-	switch *flagType {
-	case "http":
-		msgLoop(fasthttp.NewClient(*flagConnect))
-	case "fastrpc":
-		msgLoop(fastrpc.NewClient(*flagConnect))
-	case "fastprcmulty":
-		msgLoop(fastrpc.NewMultipleClient(10, *flagConnect))
+	client, err := xrpc.Dial(*flagConnect)
+	if err != nil {
+		log.Fatal(err)
 	}
+	msgLoop(client)
 }
 
 func msgLoop(client xrpc.Client) {
@@ -54,12 +52,14 @@ func msgLoop(client xrpc.Client) {
 		if resp := client.Send(msg); resp.Error() == nil {
 			var tg interface{}
 			resp.Bind(&tg)
+			resp.Release()
 			if i%1000 == 0 {
 				fmt.Println("OK", time.Now().Sub(now), tg)
 			}
 			count++
 		} else {
 			fmt.Println("err != nil", resp.Error().Error())
+			resp.Release()
 			errorCount++
 		}
 		duration += time.Now().Sub(now)
@@ -1,50 +1,57 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/geniusrabbit/xrpc"
-	"github.com/geniusrabbit/xrpc/fasthttp"
-	"github.com/geniusrabbit/xrpc/fastrpc"
+	_ "github.com/geniusrabbit/xrpc/fasthttp"
+	_ "github.com/geniusrabbit/xrpc/fastrpc"
+	"github.com/geniusrabbit/xrpc/openrpc"
 )
 
 var (
-	flagType    = flag.String("type", "http", "Client type: http, httpmulty, fastrpc")
-	flagConnect = flag.String("connect", "0.0.0.0:20202", "Connect address")
+	flagConnect = flag.String("connect", "http://0.0.0.0:20202", "Listen URL: http://host:port or fastrpc://host:port")
+	flagOpenRPC = flag.String("openrpc", "", "Write OpenRPC document into the file and exit")
 )
 
+var openrpcInfo = openrpc.Info{Title: "example", Version: "1.0.0"}
+
 type tmsg struct {
 	Name string `json:"name"`
 }
 
+type tresp struct {
+	Msg string `json:"msg"`
+}
+
 func main() {
 	flag.Parse()
 
-	fmt.Println("Run example service", *flagType, *flagConnect)
+	fmt.Println("Run example service", *flagConnect)
 
 	srv := xrpc.New()
-	srv.Register("hello", helloHandler)
+	xrpc.Handle(srv, "hello", helloHandler)
 
-	switch *flagType {
-	case "http":
-		fatalError(fasthttp.NewServer(srv).Listen(*flagConnect))
-	case "fastrpc":
-		fatalError(fastrpc.NewServer(srv).Listen(*flagConnect))
+	if *flagOpenRPC != "" {
+		fatalError(openrpc.WriteFile(srv, openrpcInfo, *flagOpenRPC))
+		return
 	}
-}
 
-func helloHandler(req xrpc.Request) error {
-	var msg tmsg
-	if err := req.Bind(&msg); err != nil {
-		return err
-	}
+	// The server is stopped by the signal after in-flight requests are finished
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	fatalError(xrpc.ListenAndServeContext(ctx, srv, *flagConnect, 5*time.Second))
+}
 
-	return req.Send(map[string]string{
-		"id":  string(req.ID()),
-		"msg": "Hello " + msg.Name + "!",
-	})
+func helloHandler(ctx context.Context, msg *tmsg) (*tresp, error) {
+	return &tresp{Msg: "Hello " + msg.Name + "!"}, nil
 }
 
 func fatalError(err error) {
@@ -6,9 +6,11 @@
 package fasthttp
 
 import (
-	"encoding/json"
-	"net/url"
+	"context"
+	"errors"
+	"net"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/demdxx/gocast"
@@ -18,46 +20,113 @@ import (
 
 // Client implementation
 type Client struct {
+	// Codec of the messages encoding, DefaultCodec is used by default
+	Codec xrpc.Codec
+
 	hostname string
 	client   *fasthttp.HostClient
+	err      error
 }
 
-// NewClient object connector
+// NewClient object connector, the hostname could be the address like
+// host:port, unix:///path, tls+tcp://host:port or URL of http/https scheme
 func NewClient(hostname string, client ...*fasthttp.HostClient) xrpc.Client {
 	var c *fasthttp.HostClient
 	if len(client) < 1 {
-		c = &fasthttp.HostClient{
-			Addr:                hostname,
-			Name:                "fasthttp-client",
-			Dial:                nil,
-			DialDualStack:       true,
-			MaxIdleConnDuration: 300 * time.Second,
-			ReadBufferSize:      256 * 1024,
-			WriteBufferSize:     256 * 1024,
-		}
+		c = newHostClient()
 	} else {
 		c = client[0]
-		c.Addr = hostname
 	}
 
-	if u, _ := url.Parse(hostname); u == nil || (u.Scheme != "http" && u.Scheme != "https") {
-		hostname = "http://" + hostname
+	address, err := parseAddress(hostname, c.IsTLS)
+	c.Addr = address.Address
+	c.IsTLS = c.IsTLS || address.TLS
+	if address.Network != "tcp" && c.Dial == nil {
+		c.Dial = func(string) (net.Conn, error) { return address.Dial() }
+	}
+
+	host := address.Address
+	if address.IsUnix() {
+		host = "localhost"
 	}
+	if c.IsTLS {
+		host = "https://" + host
+	} else {
+		host = "http://" + host
+	}
+	return &Client{hostname: host, client: c, err: err}
+}
 
-	return &Client{hostname: hostname, client: c}
+func newHostClient() *fasthttp.HostClient {
+	return &fasthttp.HostClient{
+		Name:                "fasthttp-client",
+		Dial:                nil,
+		DialDualStack:       true,
+		MaxIdleConnDuration: 300 * time.Second,
+		ReadBufferSize:      256 * 1024,
+		WriteBufferSize:     256 * 1024,
+	}
+}
+
+// parseAddress of the host, URLs of http and https schemes and hosts
+// without the scheme are converted into TCP addresses with the default port
+func parseAddress(hostname string, isTLS bool) (xrpc.Address, error) {
+	switch {
+	case strings.HasPrefix(hostname, "http://"):
+		hostname = "tcp://" + withPort(strings.TrimPrefix(hostname, "http://"), "80")
+	case strings.HasPrefix(hostname, "https://"):
+		hostname = xrpc.TLSSchemePrefix + "tcp://" + withPort(strings.TrimPrefix(hostname, "https://"), "443")
+	case !strings.Contains(hostname, "://"):
+		// The port could be omitted like in the HostClient address
+		if isTLS {
+			hostname = withPort(hostname, "443")
+		} else {
+			hostname = withPort(hostname, "80")
+		}
+	}
+	return xrpc.ParseAddress(hostname)
+}
+
+// withPort adds the port to the host if it's not defined, query is kept
+func withPort(host, port string) string {
+	host, query, hasQuery := strings.Cut(host, "?")
+	if host = strings.TrimSuffix(host, "/"); host != "" {
+		if _, _, err := net.SplitHostPort(host); err != nil {
+			host = net.JoinHostPort(host, port)
+		}
+	}
+	if hasQuery {
+		host += "?" + query
+	}
+	return host
 }
 
 // Send message to service
 func (c *Client) Send(msg xrpc.Message) xrpc.Response {
+	return c.SendContext(context.Background(), msg)
+}
+
+// SendContext message to service with cancellation and deadline of the context
+func (c *Client) SendContext(ctx context.Context, msg xrpc.Message) xrpc.Response {
+	if c.err != nil {
+		return &Response{err: c.err}
+	}
+	if err := ctx.Err(); err != nil {
+		return &Response{err: err}
+	}
+
 	var (
-		req  = fasthttp.AcquireRequest()
-		resp = fasthttp.AcquireResponse()
+		req   = fasthttp.AcquireRequest()
+		resp  = fasthttp.AcquireResponse()
+		codec = xrpc.CodecOrDefault(msg.Codec, c.Codec)
 	)
 
+	msg.Timeout = xrpc.ContextTimeout(ctx, msg.Timeout)
+
 	req.ResetBody()
 	req.SetRequestURI(c.hostname + "/" + msg.Action)
 	req.Header.SetMethod("POST")
-	req.Header.SetContentType("application/json")
+	req.Header.SetContentType(contentType(codec))
 
 	for key, val := range msg.Headers {
 		req.Header.Set(key, gocast.ToString(val))
@@ -71,16 +140,46 @@ func (c *Client) Send(msg xrpc.Message) xrpc.Response {
 		req.Header.Set(XServiceTimeout, strconv.FormatInt(int64(msg.Timeout), 10))
 	}
 
-	if err := json.NewEncoder(req.BodyWriter()).Encode(msg.Data); err != nil {
+	data, err := codec.Marshal(msg.Data)
+	if err != nil {
+		fasthttp.ReleaseRequest(req)
+		fasthttp.ReleaseResponse(resp)
 		return &Response{err: err}
 	}
+	req.SetBody(data)
 
-	if msg.Timeout <= 0 {
-		return &Response{resp: resp, err: c.client.Do(req, resp)}
+	if ctx.Done() == nil {
+		defer fasthttp.ReleaseRequest(req)
+		return &Response{resp: resp, codec: codec, err: c.do(req, resp, msg.Timeout)}
 	}
 
-	return &Response{
-		resp: resp,
-		err:  c.client.DoTimeout(req, resp, msg.Timeout),
+	done := make(chan error, 1)
+	go func() {
+		done <- c.do(req, resp, msg.Timeout)
+		fasthttp.ReleaseRequest(req)
+	}()
+
+	select {
+	case err := <-done:
+		return &Response{resp: resp, codec: codec, err: err}
+	case <-ctx.Done():
+		// The response object is still in use by the sending goroutine
+		go func() {
+			<-done
+			fasthttp.ReleaseResponse(resp)
+		}()
+		return &Response{err: ctx.Err()}
+	}
+}
+
+// do the request, timeout of the response is reported
+// as the deadline exceeded error like the server does
+func (c *Client) do(req *fasthttp.Request, resp *fasthttp.Response, timeout time.Duration) error {
+	if timeout <= 0 {
+		return c.client.Do(req, resp)
+	}
+	if err := c.client.DoTimeout(req, resp, timeout); !errors.Is(err, fasthttp.ErrTimeout) {
+		return err
 	}
+	return xrpc.ErrDeadlineExceeded
 }
@@ -0,0 +1,98 @@
+//
+// @project geniusrabbit::xrpc 2017
+// @author Dmitry Ponomarev <demdxx@gmail.com> 2017
+//
+
+package fasthttp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/geniusrabbit/xrpc"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+)
+
+// newTestClient connected to the server of the service by the in-memory listener
+func newTestClient(t *testing.T, svc xrpc.Service, opts ...ServerOption) (xrpc.Client, xrpc.Server) {
+	t.Helper()
+	var (
+		srv = NewServer(svc, opts...)
+		ln  = fasthttputil.NewInmemoryListener()
+	)
+	go func() { _ = srv.Serve(ln) }()
+	t.Cleanup(func() { _ = srv.Shutdown(context.Background()) })
+
+	client := NewClient("memory", &fasthttp.HostClient{Dial: func(addr string) (net.Conn, error) {
+		return ln.Dial()
+	}})
+	return client, srv
+}
+
+func TestSendContextCancel(t *testing.T) {
+	var (
+		svc     = xrpc.New()
+		started = make(chan struct{})
+		release = make(chan struct{})
+	)
+	_ = svc.Register("block", func(req xrpc.Request) error {
+		close(started)
+		<-release
+		return req.Send("done")
+	})
+	client, _ := newTestClient(t, svc)
+	defer close(release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+
+	var (
+		begin = time.Now()
+		resp  = client.SendContext(ctx, xrpc.Message{Action: "block"})
+	)
+	defer resp.Release()
+	if err := resp.Error(); !errors.Is(err, context.Canceled) {
+		t.Errorf("response must be cancelled: %v", err)
+	}
+	if elapsed := time.Since(begin); elapsed > time.Second {
+		t.Errorf("cancellation must not wait for the handler: %s", elapsed)
+	}
+}
+
+func TestParseAddress(t *testing.T) {
+	tests := []struct {
+		hostname string
+		tls      bool
+		network  string
+		address  string
+		secure   bool
+	}{
+		{hostname: "localhost", network: "tcp", address: "localhost:80"},
+		{hostname: "localhost", tls: true, network: "tcp", address: "localhost:443"},
+		{hostname: "localhost:8080?codec=json", network: "tcp", address: "localhost:8080"},
+		{hostname: "http://example.com", network: "tcp", address: "example.com:80"},
+		{hostname: "https://example.com/", network: "tcp", address: "example.com:443", secure: true},
+		{hostname: "tls+tcp://example.com:8443", network: "tcp", address: "example.com:8443", secure: true},
+		{hostname: "unix:///tmp/service.sock", network: "unix", address: "/tmp/service.sock"},
+	}
+	for _, test := range tests {
+		address, err := parseAddress(test.hostname, test.tls)
+		if err != nil {
+			t.Errorf("[%s] unexpected error: %v", test.hostname, err)
+			continue
+		}
+		if address.Network != test.network || address.Address != test.address || address.TLS != test.secure {
+			t.Errorf("[%s] invalid address: %+v", test.hostname, address)
+		}
+	}
+	if _, err := parseAddress("tcp://localhost", false); err == nil {
+		t.Error("TCP address without port must be invalid")
+	}
+}
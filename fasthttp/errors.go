@@ -0,0 +1,46 @@
+//
+// @project geniusrabbit::xrpc 2017
+// @author Dmitry Ponomarev <demdxx@gmail.com> 2017
+//
+
+package fasthttp
+
+import (
+	"net/http"
+
+	"github.com/geniusrabbit/xrpc"
+)
+
+// httpStatus returns HTTP status code of the error code
+func httpStatus(code xrpc.ErrorCode) int {
+	switch code {
+	case xrpc.CodeNotFound:
+		return http.StatusNotFound
+	case xrpc.CodeInvalidArgument:
+		return http.StatusBadRequest
+	case xrpc.CodeDeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case xrpc.CodeUnavailable:
+		return http.StatusServiceUnavailable
+	case xrpc.CodeOverloaded:
+		return http.StatusTooManyRequests
+	}
+	return http.StatusInternalServerError
+}
+
+// errorCode returns error code of the HTTP status code
+func errorCode(status int) xrpc.ErrorCode {
+	switch status {
+	case http.StatusNotFound:
+		return xrpc.CodeNotFound
+	case http.StatusBadRequest, http.StatusUnsupportedMediaType:
+		return xrpc.CodeInvalidArgument
+	case http.StatusGatewayTimeout, http.StatusRequestTimeout:
+		return xrpc.CodeDeadlineExceeded
+	case http.StatusServiceUnavailable, http.StatusBadGateway:
+		return xrpc.CodeUnavailable
+	case http.StatusTooManyRequests:
+		return xrpc.CodeOverloaded
+	}
+	return xrpc.CodeInternal
+}
@@ -0,0 +1,63 @@
+//
+// @project geniusrabbit::xrpc 2017
+// @author Dmitry Ponomarev <demdxx@gmail.com> 2017
+//
+
+package fasthttp
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/geniusrabbit/xrpc"
+	"github.com/valyala/fasthttp"
+)
+
+func TestErrorStatus(t *testing.T) {
+	var (
+		svc   = xrpc.New()
+		tests = []struct {
+			action string
+			err    error
+			status int
+			code   xrpc.ErrorCode
+
+			// sentinel error is restored on the client side
+			sentinel bool
+		}{
+			{action: "unknown", err: xrpc.ErrActionNotFound, status: http.StatusNotFound, code: xrpc.CodeNotFound, sentinel: true},
+			{action: "invalid", err: xrpc.NewError(xrpc.CodeInvalidArgument, "invalid name"), status: http.StatusBadRequest, code: xrpc.CodeInvalidArgument},
+			{action: "deadline", err: xrpc.ErrDeadlineExceeded, status: http.StatusGatewayTimeout, code: xrpc.CodeDeadlineExceeded, sentinel: true},
+			{action: "unavailable", err: xrpc.ErrUnavailable, status: http.StatusServiceUnavailable, code: xrpc.CodeUnavailable, sentinel: true},
+			{action: "overloaded", err: xrpc.ErrOverloaded, status: http.StatusTooManyRequests, code: xrpc.CodeOverloaded, sentinel: true},
+			{action: "internal", err: errors.New("boom"), status: http.StatusInternalServerError, code: xrpc.CodeInternal},
+		}
+	)
+	for _, test := range tests[1:] {
+		err := test.err
+		_ = svc.Register(test.action, func(req xrpc.Request) error { return err })
+	}
+	client, _ := newTestClient(t, svc)
+
+	for _, test := range tests {
+		t.Run(test.action, func(t *testing.T) {
+			resp := client.Send(xrpc.Message{Action: test.action})
+			defer resp.Release()
+
+			err := resp.Error()
+			if status := resp.Source().(*fasthttp.Response).StatusCode(); status != test.status {
+				t.Errorf("invalid status: %d", status)
+			}
+			if code := xrpc.ErrorCodeOf(err); code != test.code {
+				t.Errorf("invalid error code: %s", code)
+			}
+			if test.sentinel && !errors.Is(err, test.err) {
+				t.Errorf("client error must match the server error: %v", err)
+			}
+			if err == nil || err.Error() != test.err.Error() {
+				t.Errorf("invalid error message: %v", err)
+			}
+		})
+	}
+}
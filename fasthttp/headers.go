@@ -5,8 +5,76 @@
 
 package fasthttp
 
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/geniusrabbit/xrpc"
+)
+
 // Header constants
 const (
 	XServiceRequestID = "X-Request-Id"
 	XServiceTimeout   = "X-Service-Timeout"
+
+	// XServiceHeaders lists names of the response headers set by the action
+	// to distinguish them from the HTTP headers of the response
+	XServiceHeaders = "X-Service-Headers"
 )
+
+const (
+	contentTypePrefix     = "application/"
+	contentTypeForm       = "application/x-www-form-urlencoded"
+	contentTypeTextPrefix = "text/"
+)
+
+// contentType of the codec
+func contentType(codec xrpc.Codec) string {
+	return contentTypePrefix + codec.Name()
+}
+
+// codecByContentType returns registered codec of the content type or nil
+// if the codec is not registered. Default codec is used for the empty,
+// form or text content types sent by generic HTTP clients.
+func codecByContentType(contentType []byte, defaultCodec xrpc.Codec) xrpc.Codec {
+	if i := bytes.IndexByte(contentType, ';'); i >= 0 {
+		contentType = contentType[:i]
+	}
+	contentType = bytes.TrimSpace(contentType)
+	switch {
+	case len(contentType) == 0,
+		bytes.Equal(contentType, []byte(contentTypeForm)),
+		bytes.HasPrefix(contentType, []byte(contentTypeTextPrefix)):
+		return defaultCodec
+	case bytes.HasPrefix(contentType, []byte(contentTypePrefix)):
+		return xrpc.CodecByName(string(contentType[len(contentTypePrefix):]))
+	}
+	return nil
+}
+
+// headerListed returns true if the header name is in the comma separated list
+func headerListed(list []byte, name string) bool {
+	for len(list) > 0 {
+		var item []byte
+		if i := bytes.IndexByte(list, ','); i >= 0 {
+			item, list = list[:i], list[i+1:]
+		} else {
+			item, list = list, nil
+		}
+		if strings.EqualFold(string(bytes.TrimSpace(item)), name) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTimeout header value in nanoseconds, empty or invalid value is zero
+func parseTimeout(value []byte) time.Duration {
+	if len(value) == 0 {
+		return 0
+	}
+	timeout, _ := strconv.ParseInt(string(value), 10, 64)
+	return time.Duration(timeout)
+}
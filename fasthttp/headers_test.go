@@ -0,0 +1,37 @@
+//
+// @project geniusrabbit::xrpc 2017
+// @author Dmitry Ponomarev <demdxx@gmail.com> 2017
+//
+
+package fasthttp
+
+import (
+	"testing"
+
+	"github.com/geniusrabbit/xrpc"
+	"github.com/geniusrabbit/xrpc/codec/msgpack"
+)
+
+func TestCodecByContentType(t *testing.T) {
+	tests := []struct {
+		contentType string
+		codec       xrpc.Codec
+	}{
+		{contentType: "", codec: xrpc.GobCodec},
+		{contentType: "text/plain; charset=utf-8", codec: xrpc.GobCodec},
+		{contentType: "application/x-www-form-urlencoded", codec: xrpc.GobCodec},
+		{contentType: "application/json", codec: xrpc.JSONCodec},
+		{contentType: " application/json ; charset=utf-8", codec: xrpc.JSONCodec},
+		{contentType: "application/gob", codec: xrpc.GobCodec},
+		{contentType: "application/msgpack", codec: msgpack.Codec},
+		{contentType: "application/unknown", codec: nil},
+		{contentType: "application/xml", codec: nil},
+		{contentType: "multipart/form-data; boundary=x", codec: nil},
+	}
+	for _, test := range tests {
+		codec := codecByContentType([]byte(test.contentType), xrpc.GobCodec)
+		if codec != test.codec {
+			t.Errorf("invalid codec of [%s]: %v", test.contentType, codec)
+		}
+	}
+}
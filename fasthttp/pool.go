@@ -19,13 +19,23 @@ var (
 
 // BorrowReuest from pool
 func BorrowReuest() xrpc.Request {
-	return requestPool.Get().(xrpc.Request)
+	return acquireRequest()
 }
 
 // ReturnRequest to pool
 func ReturnRequest(req xrpc.Request) {
 	if r, _ := req.(*request); r != nil {
-		*r = request{}
-		requestPool.Put(r)
+		releaseRequest(r)
 	}
 }
+
+func acquireRequest() *request {
+	return requestPool.Get().(*request)
+}
+
+// releaseRequest resets the request and returns it to the pool,
+// the request must not be used by the action after that
+func releaseRequest(r *request) {
+	r.reset()
+	requestPool.Put(r)
+}
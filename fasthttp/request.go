@@ -6,23 +6,36 @@
 package fasthttp
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"net/http"
+	"net/textproto"
 	"time"
 
+	"github.com/geniusrabbit/xrpc"
 	"github.com/valyala/fasthttp"
 )
 
+type responseHeader struct {
+	name  string
+	value []byte
+}
+
 type request struct {
 	id      []byte
 	action  []byte
 	timeout time.Duration
 	headers map[string][]byte
 	data    []byte
+	codec   xrpc.Codec
 	ctx     context.Context
 	fastCtx *fasthttp.RequestCtx
+	params  xrpc.Params
+
+	// headersLoaded is true when headers map is filled from the source request
+	headersLoaded bool
+
+	// respHeaders are written to the response after the action
+	respHeaders []responseHeader
 }
 
 // ID of request
@@ -42,10 +55,28 @@ func (r *request) Timeout() time.Duration {
 
 // UpdateHeaders of request from source request context
 func (r *request) UpdateHeaders() {
-	r.headers = map[string][]byte{}
+	if r.headers == nil {
+		r.headers = map[string][]byte{}
+	}
 	r.fastCtx.Request.Header.VisitAll(func(key, value []byte) {
 		r.headers[string(key)] = value
 	})
+	r.headersLoaded = true
+}
+
+// Params captured by the action path pattern
+func (r *request) Params() xrpc.Params {
+	return r.params
+}
+
+// SetParams of the request, the params buffer is reused by the next request
+func (r *request) SetParams(params xrpc.Params) {
+	r.params = params
+}
+
+// Peer information of the request connection
+func (r *request) Peer() xrpc.Peer {
+	return xrpc.PeerOf(TransportName, r.fastCtx.Conn())
 }
 
 // External Context
@@ -66,29 +97,78 @@ func (r *request) Source() interface{} {
 	return r.fastCtx
 }
 
-// Headers from request
+// Header value of the request by name
+func (r *request) Header(name string) []byte {
+	return r.fastCtx.Request.Header.Peek(name)
+}
+
+// Headers from request, the map is filled on the first call
 func (r *request) Headers() map[string][]byte {
+	if !r.headersLoaded {
+		r.UpdateHeaders()
+	}
 	return r.headers
 }
 
+// SetHeader of the response
+func (r *request) SetHeader(name string, value []byte) {
+	n := len(r.respHeaders)
+	if n < cap(r.respHeaders) {
+		r.respHeaders = r.respHeaders[:n+1]
+	} else {
+		r.respHeaders = append(r.respHeaders, responseHeader{})
+	}
+	r.respHeaders[n].name = name
+	r.respHeaders[n].value = append(r.respHeaders[n].value[:0], value...)
+}
+
+// writeHeaders of the response, Send and errors reset the response
+// so headers are written after the action processing
+func (r *request) writeHeaders() {
+	if len(r.respHeaders) == 0 {
+		return
+	}
+	var names []byte
+	for _, h := range r.respHeaders {
+		name := textproto.CanonicalMIMEHeaderKey(h.name)
+		if !headerListed(names, name) {
+			if len(names) > 0 {
+				names = append(names, ',')
+			}
+			names = append(names, name...)
+		}
+		r.fastCtx.Response.Header.SetBytesV(name, h.value)
+	}
+	r.fastCtx.Response.Header.SetBytesV(XServiceHeaders, names)
+}
+
 // Bind message to object or structure
 func (r *request) Bind(target interface{}) error {
-	return json.Unmarshal(r.data, target)
+	return r.codec.Unmarshal(r.data, target)
 }
 
 // Send message as response
 func (r *request) Send(msg interface{}) error {
-	var (
-		buff bytes.Buffer
-		err  = json.NewEncoder(&buff).Encode(msg)
-	)
+	data, err := r.codec.Marshal(msg)
 	if err != nil {
 		return err
 	}
 
 	r.fastCtx.Response.Reset()
 	r.fastCtx.SetStatusCode(http.StatusOK)
-	r.fastCtx.SetBody(buff.Bytes())
+	r.fastCtx.SetContentType(contentType(r.codec))
+	r.fastCtx.SetBody(data)
 
 	return err
 }
+
+// reset request state, allocated buffers are kept for the next request
+func (r *request) reset() {
+	for key := range r.headers {
+		delete(r.headers, key)
+	}
+	for i := range r.params {
+		r.params[i] = xrpc.Param{}
+	}
+	*r = request{headers: r.headers, params: r.params[:0], respHeaders: r.respHeaders[:0]}
+}
@@ -6,8 +6,9 @@
 package fasthttp
 
 import (
-	"encoding/json"
-	"errors"
+	"net/http"
+	"net/textproto"
+	"strings"
 
 	"github.com/geniusrabbit/xrpc"
 	"github.com/valyala/fasthttp"
@@ -17,6 +18,7 @@ import (
 type Response struct {
 	parsedError bool
 	resp        *fasthttp.Response
+	codec       xrpc.Codec
 	err         error
 }
 
@@ -33,23 +35,54 @@ func (r Response) Bind(target interface{}) error {
 	if r.resp == nil {
 		return xrpc.ErrInvalidResponse
 	}
-	return json.Unmarshal(r.resp.Body(), target)
+	return xrpc.CodecOrDefault(r.codec).Unmarshal(r.resp.Body(), target)
+}
+
+// Header value of the response by name, only headers
+// set by the action are accessible like in other transports
+func (r *Response) Header(name string) []byte {
+	if r.resp == nil || !headerListed(r.resp.Header.Peek(XServiceHeaders), name) {
+		return nil
+	}
+	return r.resp.Header.Peek(name)
+}
+
+// Headers of the response set by the action, HTTP headers
+// are accessible by the source response
+func (r *Response) Headers() map[string][]byte {
+	if r.resp == nil {
+		return nil
+	}
+	var headers map[string][]byte
+	for _, name := range strings.Split(string(r.resp.Header.Peek(XServiceHeaders)), ",") {
+		if name = textproto.CanonicalMIMEHeaderKey(strings.TrimSpace(name)); name == "" {
+			continue
+		}
+		if headers == nil {
+			headers = map[string][]byte{}
+		}
+		headers[name] = r.resp.Header.Peek(name)
+	}
+	return headers
 }
 
 // Error response
 func (r *Response) Error() error {
-	if r.err == nil && r.resp != nil {
-		var err struct {
-			Error string `json:"error"`
-		}
-		if e := json.Unmarshal(r.resp.Body(), &err); e == nil {
-			if err.Error == "action not found" {
-				r.err = xrpc.ErrActionNotFound
-			} else if err.Error != "" {
-				r.err = errors.New(err.Error)
+	if r.err == nil && r.resp != nil && !r.parsedError {
+		if status := r.resp.StatusCode(); status != http.StatusOK {
+			if r.err = xrpc.UnmarshalError(r.resp.Body()); r.err == nil {
+				r.err = xrpc.NewError(errorCode(status), http.StatusText(status))
 			}
 		}
 		r.parsedError = true
 	}
 	return r.err
 }
+
+// Release the response into the pool
+func (r *Response) Release() {
+	if r.resp != nil {
+		fasthttp.ReleaseResponse(r.resp)
+		r.resp = nil
+	}
+}
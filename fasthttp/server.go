@@ -8,78 +8,292 @@ package fasthttp
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
 	"net/http"
-	"strconv"
+	"os"
+	"runtime/debug"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/geniusrabbit/xrpc"
+	"github.com/geniusrabbit/xrpc/openrpc"
 	"github.com/valyala/fasthttp"
 )
 
+// TransportName of the requests peer
+const TransportName = "http"
+
+// ServerOption of the server configuration
+type ServerOption func(srv *server)
+
+// WithCodec defines the codec used for requests without content type
+func WithCodec(codec xrpc.Codec) ServerOption {
+	return func(srv *server) {
+		srv.codec = codec
+	}
+}
+
+// WithOpenRPC serves OpenRPC document of the service by GET request of the path
+func WithOpenRPC(path string, info openrpc.Info) ServerOption {
+	return func(srv *server) {
+		srv.openrpcPath = path
+		srv.openrpcInfo = info
+	}
+}
+
+// WithConcurrency limits the number of concurrent connections
+func WithConcurrency(concurrency int) ServerOption {
+	return func(srv *server) {
+		srv.fastsrv.Concurrency = concurrency
+	}
+}
+
+// WithTLS serves all connections of the server by TLS, client certificates
+// are verified if it's required by the configuration (mutual TLS)
+func WithTLS(config *tls.Config) ServerOption {
+	return func(srv *server) {
+		srv.tlsConfig = config
+	}
+}
+
+// WithPanicReporter defines reporter of panics recovered by the server,
+// it receives panics of actions only if the service recovery is disabled
+// by xrpc.WithRecovery(false). By default panics are written to the standard logger.
+func WithPanicReporter(reporter xrpc.PanicReporter) ServerOption {
+	return func(srv *server) {
+		srv.panicReporter = reporter
+	}
+}
+
 type server struct {
-	service xrpc.Service
-	fastsrv fasthttp.Server
+	service     xrpc.Service
+	codec       xrpc.Codec
+	tlsConfig   *tls.Config
+	openrpcPath string
+	openrpcInfo openrpc.Info
+	fastsrv     fasthttp.Server
+	inflight    xrpc.Inflight
+
+	// listeners and connections are closed by the shutdown
+	mx        sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+
+	panicReporter xrpc.PanicReporter
 }
 
 // NewServer default configurated server
-func NewServer(service xrpc.Service) xrpc.Server {
-	return &server{
-		service: service,
+func NewServer(service xrpc.Service, opts ...ServerOption) xrpc.Server {
+	srv := &server{
+		service:       service,
+		panicReporter: xrpc.LogPanicReporter,
 		fastsrv: fasthttp.Server{
 			Name:        "fasthttp",
 			Concurrency: 1000,
 		},
 	}
+	srv.fastsrv.Handler = srv.handler
+	srv.fastsrv.ConnState = srv.connState
+	for _, opt := range opts {
+		opt(srv)
+	}
+	return srv
 }
 
 // Listen some address which could be any connection type like:
-// tcp://hostname:port or udp://... or unix://... etc.
+// tcp://hostname:port or unix:///path or host:port etc.
 func (s *server) Listen(address string) error {
-	s.fastsrv.Handler = s.handler
-	if strings.HasPrefix(address, "unix://") {
-		return s.fastsrv.ListenAndServeUNIX(strings.TrimPrefix(address, "unix://"), 0664)
+	if s.inflight.IsClosed() {
+		return xrpc.ErrServerClosed
+	}
+	addr, err := xrpc.ParseAddress(address)
+	if err != nil {
+		return err
+	}
+	if addr.TLS && s.tlsConfig == nil {
+		return fmt.Errorf("TLS configuration is required to listen [%s]", address)
+	}
+	listener, err := addr.Listen()
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+	if addr.IsUnix() && !strings.HasPrefix(addr.Address, "@") {
+		if err = os.Chmod(addr.Address, 0664); err != nil {
+			return err
+		}
+	}
+	return s.Serve(listener)
+}
+
+// Serve connections of the listener, connections are wrapped
+// by TLS if the server is configured with it
+func (s *server) Serve(listener net.Listener) error {
+	if s.tlsConfig != nil {
+		listener = tls.NewListener(listener, s.tlsConfig)
+	}
+
+	s.mx.Lock()
+	if s.inflight.IsClosed() {
+		s.mx.Unlock()
+		return xrpc.ErrServerClosed
+	}
+	if s.listeners == nil {
+		s.listeners = map[net.Listener]struct{}{}
+	}
+	s.listeners[listener] = struct{}{}
+	s.mx.Unlock()
+
+	err := s.fastsrv.Serve(listener)
+
+	s.mx.Lock()
+	delete(s.listeners, listener)
+	s.mx.Unlock()
+
+	if s.inflight.IsClosed() {
+		return nil
+	}
+	return err
+}
+
+// Shutdown the server gracefully, connections are closed
+// after in-flight requests are finished or the context is done
+func (s *server) Shutdown(ctx context.Context) error {
+	s.mx.Lock()
+	s.inflight.Close()
+	listeners := make([]net.Listener, 0, len(s.listeners))
+	for listener := range s.listeners {
+		listeners = append(listeners, listener)
+	}
+	s.mx.Unlock()
+
+	err := s.fastsrv.ShutdownWithContext(ctx)
+
+	// Listeners which are not started by fasthttp yet
+	for _, listener := range listeners {
+		_ = listener.Close()
+	}
+	if werr := s.inflight.Wait(ctx); werr != nil {
+		err = werr
+	}
+	s.closeConns()
+	return err
+}
+
+// connState tracks connections to close them if the shutdown is interrupted
+func (s *server) connState(conn net.Conn, state fasthttp.ConnState) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	switch state {
+	case fasthttp.StateNew:
+		if s.conns == nil {
+			s.conns = map[net.Conn]struct{}{}
+		}
+		s.conns[conn] = struct{}{}
+	case fasthttp.StateHijacked, fasthttp.StateClosed:
+		delete(s.conns, conn)
+	}
+}
+
+// closeConns of the server which are still open
+func (s *server) closeConns() {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	for conn := range s.conns {
+		_ = conn.Close()
+		delete(s.conns, conn)
 	}
-	return s.fastsrv.ListenAndServe(address)
 }
 
 func (s *server) handler(ctx *fasthttp.RequestCtx) {
+	if !s.inflight.Begin() {
+		s.handlerError(ctx, xrpc.ErrServerClosed)
+		ctx.SetConnectionClose()
+		return
+	}
+	defer s.inflight.Done()
+
+	if s.openrpcPath != "" && ctx.IsGet() && string(ctx.Path()) == s.openrpcPath {
+		s.handlerOpenRPC(ctx)
+		return
+	}
+
 	var (
-		tmHeader   = string(ctx.Request.Header.PeekBytes([]byte(XServiceTimeout)))
-		timeout, _ = strconv.ParseInt(tmHeader, 10, 64)
-		req        = &request{
-			id:      ctx.Request.Header.PeekBytes([]byte(XServiceRequestID)),
-			action:  bytes.TrimLeft(ctx.Path(), "/"),
-			data:    ctx.Request.Body(),
-			timeout: time.Duration(timeout),
-			ctx:     s.requestCtx(ctx),
-			fastCtx: ctx,
-		}
+		timeout        = parseTimeout(ctx.Request.Header.Peek(XServiceTimeout))
+		reqCtx, cancel = s.requestCtx(ctx, timeout)
+		req            = acquireRequest()
 	)
 
-	req.UpdateHeaders()
+	defer releaseRequest(req)
+	defer cancel()
+	defer s.recoverPanic(ctx, req)
+
+	req.id = ctx.Request.Header.Peek(XServiceRequestID)
+	req.action = bytes.TrimLeft(ctx.Path(), "/")
+	req.data = ctx.Request.Body()
+	req.timeout = timeout
+	req.codec = codecByContentType(ctx.Request.Header.ContentType(), xrpc.CodecOrDefault(s.codec))
+	req.ctx = reqCtx
+	req.fastCtx = ctx
+
+	if req.codec == nil {
+		s.handlerError(ctx, xrpc.NewError(xrpc.CodeInvalidArgument,
+			"unsupported content type "+string(ctx.Request.Header.ContentType())))
+		ctx.SetStatusCode(http.StatusUnsupportedMediaType)
+		return
+	}
+
+	// Skip processing of the request which caller already gave up
+	if reqCtx.Err() != nil {
+		s.handlerError(ctx, xrpc.ErrDeadlineExceeded)
+		return
+	}
 
 	if err := s.service.Handle(req); err != nil {
-		if err == xrpc.ErrActionNotFound {
-			s.handlerNotFound(ctx)
-		} else {
-			s.handlerError(ctx, err)
-		}
+		s.handlerError(ctx, err)
 	}
+	req.writeHeaders()
 }
 
-func (s *server) handlerError(ctx *fasthttp.RequestCtx, err error) {
+// handlerOpenRPC generates the document on each request
+// because actions could be changed at runtime
+func (s *server) handlerOpenRPC(ctx *fasthttp.RequestCtx) {
+	data, err := openrpc.New(s.service, s.openrpcInfo).JSON()
+	if err != nil {
+		s.handlerError(ctx, err)
+		return
+	}
 	ctx.Response.Reset()
-	ctx.SetStatusCode(http.StatusInternalServerError)
-	ctx.SetBody([]byte(`{"error":"` + strings.Replace(err.Error(), `"`, `\"`, -1) + `"}`))
+	ctx.SetContentType("application/json")
+	ctx.SetBody(data)
+}
+
+// recoverPanic replies with the internal error, the server is always
+// protected whether the recovery of the service is disabled or not
+func (s *server) recoverPanic(ctx *fasthttp.RequestCtx, req *request) {
+	if rec := recover(); rec != nil {
+		if s.panicReporter != nil {
+			s.panicReporter(req, rec, debug.Stack())
+		}
+		s.handlerError(ctx, xrpc.ErrInternal)
+	}
 }
 
-func (s *server) handlerNotFound(ctx *fasthttp.RequestCtx) {
+func (s *server) handlerError(ctx *fasthttp.RequestCtx, err error) {
 	ctx.Response.Reset()
-	ctx.SetStatusCode(http.StatusNotFound)
-	ctx.SetBody([]byte(`{"error":"action not found"}`))
+	ctx.SetStatusCode(httpStatus(xrpc.ErrorCodeOf(err)))
+	ctx.SetContentType("application/json")
+	ctx.SetBody(xrpc.MarshalError(err))
 }
 
-func (s *server) requestCtx(ctx *fasthttp.RequestCtx) context.Context {
-	return context.Background()
+// requestCtx returns the context limited by the propagated timeout
+// which counts from the moment of the request receiving
+func (s *server) requestCtx(ctx *fasthttp.RequestCtx, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.Background(), func() {}
+	}
+	return context.WithDeadline(context.Background(), ctx.Time().Add(timeout))
 }
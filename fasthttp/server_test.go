@@ -0,0 +1,375 @@
+//
+// @project geniusrabbit::xrpc 2017
+// @author Dmitry Ponomarev <demdxx@gmail.com> 2017
+//
+
+package fasthttp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/geniusrabbit/xrpc"
+	"github.com/geniusrabbit/xrpc/codec/msgpack"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+)
+
+type benchMessage struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+func BenchmarkServerHandler(b *testing.B) {
+	var (
+		svc  = xrpc.New()
+		srv  = NewServer(svc).(*server)
+		resp = []byte(`"pong"`)
+	)
+	_ = svc.Register("ping", func(req xrpc.Request) error {
+		req.SetHeader("X-Ping", req.Header("X-Request-Id"))
+		req.Source().(*fasthttp.RequestCtx).SetBody(resp)
+		return nil
+	})
+	_ = svc.Register("echo", func(req xrpc.Request) error {
+		var msg benchMessage
+		if err := req.Bind(&msg); err != nil {
+			return err
+		}
+		msg.Count++
+		return req.Send(&msg)
+	})
+
+	for _, bench := range []struct {
+		name string
+		body string
+	}{
+		{name: "ping", body: `"ping"`},
+		{name: "echo", body: `{"name":"bench","count":1}`},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			var ctx fasthttp.RequestCtx
+			ctx.Request.SetRequestURI("/" + bench.name)
+			ctx.Request.Header.SetMethod("POST")
+			ctx.Request.Header.Set(XServiceRequestID, "1")
+			ctx.Request.SetBodyString(bench.body)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				srv.handler(&ctx)
+			}
+			if status := ctx.Response.StatusCode(); status != http.StatusOK {
+				b.Fatalf("invalid status: %d %s", status, ctx.Response.Body())
+			}
+		})
+	}
+}
+
+func TestServeListener(t *testing.T) {
+	var (
+		svc = xrpc.New()
+		srv = NewServer(svc)
+		ln  = fasthttputil.NewInmemoryListener()
+	)
+	_ = svc.Register("ping", func(req xrpc.Request) error {
+		return req.Send("pong")
+	})
+
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve(ln) }()
+
+	client := NewClient("memory", &fasthttp.HostClient{Dial: func(addr string) (net.Conn, error) {
+		return ln.Dial()
+	}})
+
+	var out string
+	resp := client.Send(xrpc.Message{Action: "ping"})
+	if err := resp.Bind(&out); err != nil || out != "pong" {
+		t.Errorf("invalid response: %q %v", out, err)
+	}
+	resp.Release()
+
+	if err := srv.Shutdown(context.Background()); err != nil {
+		t.Error(err)
+	}
+	if err := <-served; err != nil {
+		t.Errorf("serve must be finished without error: %v", err)
+	}
+	if err := srv.Serve(ln); err != xrpc.ErrServerClosed {
+		t.Errorf("serve after shutdown must fail: %v", err)
+	}
+}
+
+func TestDeadlineExceeded(t *testing.T) {
+	var (
+		svc    = xrpc.New()
+		ctxErr = make(chan error, 1)
+	)
+	_ = svc.Register("slow", func(req xrpc.Request) error {
+		select {
+		case <-req.Context().Done():
+		case <-time.After(time.Second):
+		}
+		ctxErr <- req.Context().Err()
+		return req.Context().Err()
+	})
+	client, _ := newTestClient(t, svc)
+
+	_, err := xrpc.Call[string, string](context.Background(), client, "slow", "", xrpc.WithTimeout(50*time.Millisecond))
+	if !errors.Is(err, xrpc.ErrDeadlineExceeded) {
+		t.Errorf("client must receive deadline exceeded error: %v", err)
+	}
+	if err = <-ctxErr; !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("handler context must be expired by the request timeout: %v", err)
+	}
+}
+
+func TestPanicRecovery(t *testing.T) {
+	var (
+		reported interface{}
+		svc      = xrpc.New(xrpc.WithRecovery(false))
+		reporter = func(req xrpc.Request, recovered interface{}, stack []byte) {
+			reported = recovered
+		}
+	)
+	_ = svc.Register("panic", func(req xrpc.Request) error { panic("test panic") })
+	client, _ := newTestClient(t, svc, WithPanicReporter(reporter))
+
+	resp := client.Send(xrpc.Message{Action: "panic"})
+	defer resp.Release()
+	if err := resp.Error(); !errors.Is(err, xrpc.ErrInternal) {
+		t.Errorf("panic must be replied by internal error: %v", err)
+	}
+	if status := resp.Source().(*fasthttp.Response).StatusCode(); status != http.StatusInternalServerError {
+		t.Errorf("invalid status: %d", status)
+	}
+	if reported != "test panic" {
+		t.Errorf("panic must be reported: %v", reported)
+	}
+}
+
+func TestServiceRecovery(t *testing.T) {
+	var (
+		reported      interface{}
+		serverReports int
+		svc           = xrpc.New(xrpc.WithPanicReporter(func(req xrpc.Request, recovered interface{}, stack []byte) {
+			reported = recovered
+		}))
+	)
+	_ = svc.Register("panic", func(req xrpc.Request) error { panic("test panic") })
+	client, _ := newTestClient(t, svc, WithPanicReporter(func(req xrpc.Request, recovered interface{}, stack []byte) {
+		serverReports++
+	}))
+
+	resp := client.Send(xrpc.Message{Action: "panic"})
+	defer resp.Release()
+	if err := resp.Error(); !errors.Is(err, xrpc.ErrInternal) {
+		t.Errorf("panic must be replied by internal error: %v", err)
+	}
+	if reported != "test panic" || serverReports != 0 {
+		t.Errorf("panic must be reported by the service only: %v %d", reported, serverReports)
+	}
+}
+
+func TestHeadersCase(t *testing.T) {
+	svc := xrpc.New()
+	_ = svc.Register("echo", func(req xrpc.Request) error {
+		req.SetHeader("x-reply-ID", req.Header("x-request-user"))
+		return req.Send(string(req.Headers()["X-Request-User"]))
+	})
+	client, _ := newTestClient(t, svc)
+
+	resp := client.Send(xrpc.Message{
+		Action:  "echo",
+		Headers: map[string]interface{}{"X-REQUEST-user": "user1"},
+	})
+	defer resp.Release()
+
+	var res string
+	if err := resp.Bind(&res); err != nil || res != "user1" {
+		t.Errorf("request header must be case insensitive: %s %v", res, err)
+	}
+	if value := resp.Header("x-reply-id"); string(value) != "user1" {
+		t.Errorf("response header must be case insensitive: %s", value)
+	}
+	if value := resp.Headers()["X-Reply-Id"]; string(value) != "user1" {
+		t.Errorf("response headers must be canonical: %v", resp.Headers())
+	}
+
+	// HTTP headers are not headers of the action like in other transports
+	if headers := resp.Headers(); len(headers) != 1 || resp.Header("Content-Type") != nil {
+		t.Errorf("only action headers expected: %v", headers)
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	var (
+		svc     = xrpc.New()
+		started = make(chan struct{})
+		release = make(chan struct{})
+	)
+	_ = svc.Register("block", func(req xrpc.Request) error {
+		close(started)
+		<-release
+		return req.Send("done")
+	})
+	client, xsrv := newTestClient(t, svc)
+	srv := xsrv.(*server)
+
+	inflight := make(chan error, 1)
+	go func() {
+		res, err := xrpc.Call[string, string](context.Background(), client, "block", "")
+		if err == nil && res != "done" {
+			err = errors.New("invalid response: " + res)
+		}
+		inflight <- err
+	}()
+	<-started
+
+	shutdown := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		shutdown <- srv.Shutdown(ctx)
+	}()
+	for !srv.inflight.IsClosed() {
+		time.Sleep(time.Millisecond)
+	}
+
+	// New requests of existing connections are rejected during the shutdown
+	var ctx fasthttp.RequestCtx
+	ctx.Request.SetRequestURI("/block")
+	ctx.Request.Header.SetMethod("POST")
+	srv.handler(&ctx)
+	if err := xrpc.UnmarshalError(ctx.Response.Body()); !errors.Is(err, xrpc.ErrServerClosed) {
+		t.Errorf("new request must be rejected: %v", err)
+	}
+	if status := ctx.Response.StatusCode(); status != http.StatusServiceUnavailable {
+		t.Errorf("invalid status: %d", status)
+	}
+
+	close(release)
+	if err := <-inflight; err != nil {
+		t.Errorf("in-flight request must be finished: %v", err)
+	}
+	if err := <-shutdown; err != nil {
+		t.Errorf("shutdown must wait for in-flight request: %v", err)
+	}
+}
+
+func TestShutdownInterrupted(t *testing.T) {
+	var (
+		svc     = xrpc.New()
+		started = make(chan struct{})
+		release = make(chan struct{})
+	)
+	defer close(release)
+	_ = svc.Register("block", func(req xrpc.Request) error {
+		close(started)
+		<-release
+		return req.Send("done")
+	})
+	client, srv := newTestClient(t, svc)
+
+	inflight := make(chan error, 1)
+	go func() {
+		_, err := xrpc.Call[string, string](context.Background(), client, "block", "")
+		inflight <- err
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	var shutdownErr *xrpc.ShutdownError
+	if err := srv.Shutdown(ctx); !errors.As(err, &shutdownErr) || shutdownErr.Interrupted != 1 {
+		t.Errorf("shutdown must report the interrupted request: %v", err)
+	}
+
+	// Connections are closed like by the fastrpc server
+	select {
+	case err := <-inflight:
+		if err == nil {
+			t.Error("interrupted request must fail")
+		}
+	case <-time.After(time.Second):
+		t.Error("connection of the interrupted request must be closed")
+	}
+}
+
+func TestServeShutdownRace(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		var (
+			srv    = NewServer(xrpc.New())
+			served = make(chan error, 1)
+		)
+		go func() { served <- srv.Serve(fasthttputil.NewInmemoryListener()) }()
+		_ = srv.Shutdown(context.Background())
+
+		select {
+		case err := <-served:
+			if err != nil && !errors.Is(err, xrpc.ErrServerClosed) {
+				t.Fatal(err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("serve must be stopped by the concurrent shutdown")
+		}
+	}
+}
+
+func TestContentTypeNegotiation(t *testing.T) {
+	type message struct {
+		Name string `json:"name" msgpack:"name"`
+	}
+	var (
+		svc = xrpc.New()
+		srv = NewServer(svc).(*server)
+	)
+	_ = svc.Register("echo", func(req xrpc.Request) error {
+		var msg message
+		if err := req.Bind(&msg); err != nil {
+			return xrpc.NewError(xrpc.CodeInvalidArgument, err.Error())
+		}
+		return req.Send(&msg)
+	})
+
+	for _, codec := range []xrpc.Codec{xrpc.JSONCodec, xrpc.GobCodec, msgpack.Codec} {
+		var (
+			out       message
+			ctx       fasthttp.RequestCtx
+			data, err = codec.Marshal(&message{Name: "test"})
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx.Request.SetRequestURI("/echo")
+		ctx.Request.Header.SetMethod("POST")
+		ctx.Request.Header.SetContentType(contentType(codec))
+		ctx.Request.SetBody(data)
+		srv.handler(&ctx)
+
+		if err = codec.Unmarshal(ctx.Response.Body(), &out); err != nil || out.Name != "test" {
+			t.Errorf("invalid response of [%s] codec: %+v %v", codec.Name(), out, err)
+		}
+		if contentType := ctx.Response.Header.ContentType(); string(contentType) != "application/"+codec.Name() {
+			t.Errorf("response must be encoded by [%s] codec: %s", codec.Name(), contentType)
+		}
+	}
+
+	var ctx fasthttp.RequestCtx
+	ctx.Request.SetRequestURI("/echo")
+	ctx.Request.Header.SetMethod("POST")
+	ctx.Request.Header.SetContentType("application/unregistered")
+	ctx.Request.SetBodyString(`{"name":"test"}`)
+	srv.handler(&ctx)
+	if status := ctx.Response.StatusCode(); status != http.StatusUnsupportedMediaType {
+		t.Errorf("unregistered codec must be rejected: %d", status)
+	}
+	if code := xrpc.ErrorCodeOf(xrpc.UnmarshalError(ctx.Response.Body())); code != xrpc.CodeInvalidArgument {
+		t.Errorf("invalid error code: %s", code)
+	}
+}
@@ -0,0 +1,67 @@
+//
+// @project geniusrabbit::xrpc 2017
+// @author Dmitry Ponomarev <demdxx@gmail.com> 2017
+//
+
+package fasthttp
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/geniusrabbit/xrpc"
+	"github.com/geniusrabbit/xrpc/internal/testcert"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+)
+
+func TestServeMutualTLS(t *testing.T) {
+	var (
+		serverCert, serverKey = testcert.SelfSigned(t, "server")
+		clientCert, clientKey = testcert.SelfSigned(t, "client")
+		svc                   = xrpc.New()
+		ln                    = fasthttputil.NewInmemoryListener()
+	)
+	_ = svc.Register("whoami", func(req xrpc.Request) error {
+		return req.Send(req.Peer().Subject())
+	})
+
+	serverConfig, err := xrpc.TLSOptions{CertFile: serverCert, KeyFile: serverKey, CAFile: clientCert}.ServerConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := NewServer(svc, WithTLS(serverConfig))
+	go func() { _ = srv.Serve(ln) }()
+	defer srv.Shutdown(context.Background())
+
+	newClient := func(opts xrpc.TLSOptions) xrpc.Client {
+		config, err := opts.ClientConfig()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return NewClient("https://memory", &fasthttp.HostClient{
+			TLSConfig: config,
+			Dial:      func(addr string) (net.Conn, error) { return ln.Dial() },
+		})
+	}
+
+	client := newClient(xrpc.TLSOptions{CertFile: clientCert, KeyFile: clientKey, CAFile: serverCert, ServerName: "localhost"})
+	var subject string
+	resp := client.Send(xrpc.Message{Action: "whoami"})
+	if err = resp.Bind(&subject); err != nil || subject != "CN=client" {
+		t.Errorf("invalid peer of the client: %q %v", subject, err)
+	}
+	resp.Release()
+
+	client = newClient(xrpc.TLSOptions{CAFile: serverCert, ServerName: "localhost"})
+	resp = client.Send(xrpc.Message{Action: "whoami"})
+	if resp.Error() == nil {
+		t.Error("client without certificate must be rejected")
+	}
+	resp.Release()
+
+	if err = NewServer(svc).Listen("tls+tcp://127.0.0.1:0"); err == nil {
+		t.Error("listen TLS address without configuration must fail")
+	}
+}
@@ -0,0 +1,84 @@
+//
+// @project geniusrabbit::xrpc 2017
+// @author Dmitry Ponomarev <demdxx@gmail.com> 2017
+//
+
+package fasthttp
+
+import (
+	"github.com/geniusrabbit/xrpc"
+)
+
+func init() {
+	xrpc.RegisterTransport("http", transport{})
+	xrpc.RegisterTransport("https", transport{tls: true})
+}
+
+// transport of the `http` and `https` URL schemes
+type transport struct {
+	tls bool
+}
+
+// DefaultPort of the addresses without the port
+func (t transport) DefaultPort() string {
+	if t.tls {
+		return "443"
+	}
+	return "80"
+}
+
+// Dial client of the address, supported options:
+//
+//	codec        name of the registered codec
+//	max_conns    maximum number of connections to the host
+//	ca           file of certificates to verify the server
+//	cert, key    files of the client certificate for mutual TLS
+//	server_name  verified name of the server, host by default
+//	insecure     skip verification of the server
+func (t transport) Dial(address xrpc.Address) (xrpc.Client, error) {
+	address.TLS = address.TLS || t.tls
+
+	codec, err := address.Codec()
+	if err != nil {
+		return nil, err
+	}
+	hostClient := newHostClient()
+	if hostClient.MaxConns, err = address.Int("max_conns", 0); err != nil {
+		return nil, err
+	}
+	if address.TLS {
+		if hostClient.TLSConfig, err = address.TLSOptions().ClientConfig(); err != nil {
+			return nil, err
+		}
+	}
+
+	client := NewClient(address.String(), hostClient).(*Client)
+	client.Codec = codec
+	return client, client.err
+}
+
+// NewServer of the service, supported options:
+//
+//	codec        name of the codec of requests without content type
+//	concurrency  maximum number of concurrent connections
+//	cert, key    files of the server certificate, required for TLS
+//	ca           file of certificates to verify clients (mutual TLS)
+func (t transport) NewServer(svc xrpc.Service, address xrpc.Address) (xrpc.Server, error) {
+	codec, err := address.Codec()
+	if err != nil {
+		return nil, err
+	}
+	concurrency, err := address.Int("concurrency", 1000)
+	if err != nil {
+		return nil, err
+	}
+	opts := []ServerOption{WithCodec(codec), WithConcurrency(concurrency)}
+	if address.TLS || t.tls {
+		config, err := address.TLSOptions().ServerConfig()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithTLS(config))
+	}
+	return NewServer(svc, opts...), nil
+}
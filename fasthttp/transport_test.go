@@ -0,0 +1,56 @@
+//
+// @project geniusrabbit::xrpc 2017
+// @author Dmitry Ponomarev <demdxx@gmail.com> 2017
+//
+
+package fasthttp
+
+import (
+	"testing"
+
+	"github.com/geniusrabbit/xrpc"
+	"github.com/geniusrabbit/xrpc/codec/msgpack"
+)
+
+func TestTransportDial(t *testing.T) {
+	tests := []struct {
+		url      string
+		hostname string
+		addr     string
+		tls      bool
+		codec    xrpc.Codec
+		maxConns int
+	}{
+		{url: "http://example.com", hostname: "http://example.com:80", addr: "example.com:80"},
+		{url: "https://example.com", hostname: "https://example.com:443", addr: "example.com:443", tls: true},
+		{url: "http://example.com:8080?codec=msgpack&max_conns=3", hostname: "http://example.com:8080", addr: "example.com:8080", codec: msgpack.Codec, maxConns: 3},
+		{url: "http+tls+tcp://example.com:8443?insecure=true", hostname: "https://example.com:8443", addr: "example.com:8443", tls: true},
+	}
+	for _, test := range tests {
+		client, err := xrpc.Dial(test.url)
+		if err != nil {
+			t.Errorf("[%s] unexpected error: %v", test.url, err)
+			continue
+		}
+		c, ok := client.(*Client)
+		if !ok {
+			t.Errorf("[%s] invalid client type: %T", test.url, client)
+			continue
+		}
+		if c.hostname != test.hostname || c.client.Addr != test.addr || c.client.IsTLS != test.tls {
+			t.Errorf("[%s] invalid client address: %s %s %t", test.url, c.hostname, c.client.Addr, c.client.IsTLS)
+		}
+		if c.Codec != test.codec || c.client.MaxConns != test.maxConns {
+			t.Errorf("[%s] invalid client options: %v %d", test.url, c.Codec, c.client.MaxConns)
+		}
+		if test.tls && c.client.TLSConfig == nil {
+			t.Errorf("[%s] TLS configuration expected", test.url)
+		}
+	}
+
+	for _, url := range []string{"http://example.com?codec=unknown", "http://example.com?max_conns=many", "http+tcp://example.com"} {
+		if _, err := xrpc.Dial(url); err == nil {
+			t.Errorf("[%s] error expected", url)
+		}
+	}
+}
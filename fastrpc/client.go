@@ -6,33 +6,77 @@
 package fastrpc
 
 import (
+	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net"
-	"net/url"
+	"net/textproto"
 	"time"
 
+	"github.com/demdxx/gocast"
 	"github.com/geniusrabbit/xrpc"
 	"github.com/valyala/fastrpc"
 	"github.com/valyala/fastrpc/tlv"
 )
 
+var errTLSServerName = errors.New("TLS server name is required to verify the server")
+
+// DefaultTimeout of the request if neither message timeout nor context deadline is defined
+var DefaultTimeout = 100 * time.Millisecond
+
 // Client implementation
 type Client struct {
-	client *fastrpc.Client
+	// Codec of the messages encoding, DefaultCodec is used by default
+	Codec xrpc.Codec
+
+	client    *fastrpc.Client
+	tlsConfig *tls.Config
+	err       error
+}
+
+// ClientOption of the client configuration
+type ClientOption func(c *Client)
+
+// WithProtocolVersion of the messages envelope, ProtocolVersionJSON
+// is used to communicate with servers which don't support the binary envelope
+func WithProtocolVersion(version byte) ClientOption {
+	return func(c *Client) {
+		c.client.ProtocolVersion = version
+	}
+}
+
+// WithRequestCompress defines the compression of requests
+func WithRequestCompress(compress CompressType) ClientOption {
+	return func(c *Client) {
+		c.client.CompressType = fastrpc.CompressType(compress)
+	}
+}
+
+// WithTLSConfig of the secure connection, it's used for addresses without
+// TLS scheme as well. The server name of the config is required for unix sockets.
+func WithTLSConfig(config *tls.Config) ClientOption {
+	return func(c *Client) {
+		c.tlsConfig = config
+	}
 }
 
-// NewClient connector
-func NewClient(addr string) xrpc.Client {
-	addr, dial := dialer(addr)
-	return &Client{
+// NewClient connector of the binary envelope protocol, the version is not
+// negotiated and servers of the previous releases require
+// the WithProtocolVersion(ProtocolVersionJSON) option with the JSON codec
+func NewClient(addr string, opts ...ClientOption) xrpc.Client {
+	con, err := dialer(addr)
+	c := &Client{
+		tlsConfig: con.TLSConfig,
+		err:       err,
 		client: &fastrpc.Client{
 			SniffHeader:           "fastrpc",
-			ProtocolVersion:       0,
+			ProtocolVersion:       ProtocolVersion,
 			NewResponse:           func() fastrpc.ResponseReader { return &tlv.Response{} },
-			Addr:                  addr,
+			Addr:                  con.Addr,
 			CompressType:          fastrpc.CompressNone,
-			Dial:                  dial,
-			TLSConfig:             nil,
+			Dial:                  con.Dial,
 			MaxPendingRequests:    0,
 			MaxBatchDelay:         0,
 			ReadTimeout:           0 * time.Millisecond,
@@ -42,74 +86,201 @@ func NewClient(addr string) xrpc.Client {
 			PrioritizeNewRequests: false,
 		},
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.client.Dial = tlsDial(c.client.Dial, c.tlsConfig)
+	return c
 }
 
 // Send message to service
 func (c *Client) Send(msg xrpc.Message) xrpc.Response {
-	return sendMessage(c.client, msg)
+	if c.err != nil {
+		return &Response{err: c.err}
+	}
+	return sendMessage(context.Background(), c.client, c.Codec, msg)
 }
 
-func sendMessage(client *fastrpc.Client, msg xrpc.Message) xrpc.Response {
+// SendContext message to service with cancellation and deadline of the context
+func (c *Client) SendContext(ctx context.Context, msg xrpc.Message) xrpc.Response {
+	if c.err != nil {
+		return &Response{err: c.err}
+	}
+	return sendMessage(ctx, c.client, c.Codec, msg)
+}
+
+func sendMessage(ctx context.Context, client *fastrpc.Client, codec xrpc.Codec, msg xrpc.Message) xrpc.Response {
+	if err := ctx.Err(); err != nil {
+		return &Response{err: err}
+	}
+
 	var (
 		req     = tlv.AcquireRequest()
-		timeout = msg.Timeout
+		version = client.ProtocolVersion
+		timeout = xrpc.ContextTimeout(ctx, msg.Timeout)
 	)
 
-	defer tlv.ReleaseRequest(req)
-
-	if err := json.NewEncoder(req).Encode(struct {
-		ID      string        `json:"id,omitempty"`
-		Timeout time.Duration `json:"timeout,omitempty"`
-		Headers interface{}   `json:"headers,omitempty"`
-		Data    interface{}   `json:"data"`
-	}{
-		ID:      msg.ID,
-		Timeout: msg.Timeout,
-		Headers: mapOrNil(msg.Headers),
-		Data:    msg.Data,
-	}); err != nil {
+	codec = xrpc.CodecOrDefault(msg.Codec, codec)
+	data, err := codec.Marshal(msg.Data)
+	if err != nil {
+		tlv.ReleaseRequest(req)
+		return &Response{err: err}
+	}
+
+	// Servers receive the absolute deadline to count the time spent on the way,
+	// it's limited by the timeout if clocks of the client and the server differ
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	if version == ProtocolVersionJSON {
+		err = encodeJSONRequest(req, codec, msg, timeout, deadline, data)
+	} else {
+		req.SwapValue(appendRequestEnvelope(req.SwapValue(nil)[:0],
+			msg.ID, deadline, timeout, codec.Name(), headersOf(msg.Headers), data))
+	}
+	if err != nil {
+		tlv.ReleaseRequest(req)
 		return &Response{err: err}
 	}
 
 	req.SetName(msg.Action)
-	if msg.Timeout <= 0 {
+	if timeout <= 0 {
 		timeout = client.MaxBatchDelay
 	}
 	if timeout <= 0 {
-		timeout = 100 * time.Millisecond
+		timeout = DefaultTimeout
+	}
+
+	var resp = tlv.AcquireResponse()
+	deadline = time.Now().Add(timeout)
+
+	if ctx.Done() == nil {
+		defer tlv.ReleaseRequest(req)
+		return &Response{version: version, resp: resp, codec: codec, err: doDeadline(client, req, resp, deadline)}
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- doDeadline(client, req, resp, deadline)
+		tlv.ReleaseRequest(req)
+	}()
+
+	select {
+	case err := <-done:
+		return &Response{version: version, resp: resp, codec: codec, err: err}
+	case <-ctx.Done():
+		// The response object is still in use by the sending goroutine
+		go func() {
+			<-done
+			tlv.ReleaseResponse(resp)
+		}()
+		return &Response{err: ctx.Err()}
+	}
+}
+
+// doDeadline sends the request, timeout of the response is reported
+// as the deadline exceeded error like the server does
+func doDeadline(client *fastrpc.Client, req *tlv.Request, resp *tlv.Response, deadline time.Time) error {
+	err := client.DoDeadline(req, resp, deadline)
+	if err == fastrpc.ErrTimeout {
+		return xrpc.ErrDeadlineExceeded
+	}
+	return err
+}
+
+// encodeJSONRequest of the legacy envelope
+func encodeJSONRequest(req *tlv.Request, codec xrpc.Codec, msg xrpc.Message, timeout time.Duration, deadline time.Time, data []byte) error {
+	envelope := envelopeMessage{
+		ID:       msg.ID,
+		Timeout:  timeout,
+		Headers:  headersOf(msg.Headers),
+		Envelope: true,
+	}
+	if !deadline.IsZero() {
+		envelope.Deadline = deadline.UnixNano()
 	}
 
-	resp := tlv.AcquireResponse()
-	return &Response{
-		resp: resp,
-		err:  client.DoDeadline(req, resp, time.Now().Add(timeout)),
+	// JSON data is embedded into the envelope as is
+	if codec.Name() == xrpc.JSONCodec.Name() {
+		envelope.Data = data
+	} else {
+		envelope.Codec = codec.Name()
+		envelope.Payload = data
 	}
+	return json.NewEncoder(req).Encode(&envelope)
 }
 
-func mapOrNil(m map[string]interface{}) map[string]interface{} {
-	if m == nil || len(m) < 1 {
+type envelopeMessage struct {
+	ID       string            `json:"id,omitempty"`
+	Timeout  time.Duration     `json:"timeout,omitempty"`
+	Deadline int64             `json:"deadline,omitempty"`
+	Headers  map[string]string `json:"headers,omitempty"`
+	Codec    string            `json:"codec,omitempty"`
+	Data     json.RawMessage   `json:"data,omitempty"`
+	Payload  []byte            `json:"payload,omitempty"`
+	Envelope bool              `json:"envelope,omitempty"`
+}
+
+// headersOf message converted to the string values with canonical keys
+func headersOf(m map[string]interface{}) map[string]string {
+	if len(m) < 1 {
 		return nil
 	}
-	return m
+	headers := make(map[string]string, len(m))
+	for key, value := range m {
+		headers[textproto.CanonicalMIMEHeaderKey(key)] = gocast.ToString(value)
+	}
+	return headers
 }
 
-func dialer(addr string) (string, func(addr string) (net.Conn, error)) {
-	var (
-		url, err = url.Parse(addr)
-		scheme   string
-	)
+// dialer of the address, TCP addresses are dialed by the default dialer.
+// TLS addresses are configured by the address options like `server_name`,
+// which is required for unix sockets.
+func dialer(addr string) (connAddr, error) {
+	address, err := xrpc.ParseAddress(addr)
 	if err != nil {
-		return addr, nil
+		return connAddr{Addr: addr}, err
 	}
-
-	switch url.Scheme {
-	case "", "tcp", "tcp4", "tcp6":
-		return url.Host, nil
-	default:
-		scheme = url.Scheme
+	con := connAddr{Addr: address.Address}
+	if address.Network != "tcp" {
+		con.Dial = func(string) (net.Conn, error) { return address.Dial() }
+	}
+	if address.TLS {
+		if con.TLSConfig, err = address.TLSOptions().ClientConfig(); err != nil {
+			return con, err
+		}
+		if address.IsUnix() && con.TLSConfig.ServerName == "" && !con.TLSConfig.InsecureSkipVerify {
+			return con, fmt.Errorf("server_name option is required for the address [%s]", addr)
+		}
 	}
+	return con, nil
+}
 
-	return url.Host, func(addr string) (net.Conn, error) {
-		return net.Dial(scheme, addr)
+// tlsDial wraps connections of the dial function by TLS client,
+// the whole connection including the protocol handshake is encrypted.
+// The host of the address is verified if the server name is not defined.
+func tlsDial(dial func(addr string) (net.Conn, error), config *tls.Config) func(addr string) (net.Conn, error) {
+	if config == nil {
+		return dial
+	}
+	if dial == nil {
+		dial = func(addr string) (net.Conn, error) { return net.Dial("tcp", addr) }
+	}
+	return func(addr string) (net.Conn, error) {
+		cfg := config
+		if cfg.ServerName == "" && !cfg.InsecureSkipVerify {
+			host, _, err := net.SplitHostPort(addr)
+			if err != nil {
+				return nil, errTLSServerName
+			}
+			cfg = config.Clone()
+			cfg.ServerName = host
+		}
+		conn, err := dial(addr)
+		if err != nil {
+			return nil, err
+		}
+		return tls.Client(conn, cfg), nil
 	}
 }
@@ -0,0 +1,213 @@
+//
+// @project geniusrabbit::xrpc 2017
+// @author Dmitry Ponomarev <demdxx@gmail.com> 2017
+//
+
+package fastrpc
+
+import (
+	"encoding/binary"
+	"errors"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// Protocol versions announced by the client in the connection handshake.
+// The version is not negotiated: servers accept clients of both versions,
+// but servers released before the binary envelope close connections
+// of ProtocolVersion, clients of such servers must be configured
+// by WithProtocolVersion(ProtocolVersionJSON). Responses of such servers
+// contain the action data only, without the envelope and headers.
+const (
+	// ProtocolVersionJSON of the legacy JSON envelope
+	ProtocolVersionJSON byte = 0
+
+	// ProtocolVersion of the binary envelope, it's used by clients by default
+	ProtocolVersion byte = 1
+)
+
+// Response flags of the binary envelope
+const (
+	responseFlagError byte = 1 << iota
+)
+
+var errInvalidEnvelope = errors.New("invalid message envelope")
+
+type header struct {
+	name  []byte
+	value []byte
+}
+
+// Binary request envelope:
+//
+//	uvarint(len(id)) id
+//	varint(deadline unix nanoseconds, 0 without deadline)
+//	varint(timeout nanoseconds, 0 without timeout)
+//	uvarint(len(codec)) codec name, empty for the default codec
+//	uvarint(count of headers) [uvarint(len(name)) name uvarint(len(value)) value]...
+//	payload up to the end
+func appendRequestEnvelope(dst []byte, id string, deadline time.Time, timeout time.Duration, codec string, headers map[string]string, payload []byte) []byte {
+	dst = appendString(dst, id)
+	if deadline.IsZero() {
+		dst = binary.AppendVarint(dst, 0)
+	} else {
+		dst = binary.AppendVarint(dst, deadline.UnixNano())
+	}
+	dst = binary.AppendVarint(dst, int64(timeout))
+	dst = appendString(dst, codec)
+	dst = binary.AppendUvarint(dst, uint64(len(headers)))
+	for name, value := range headers {
+		dst = appendString(dst, name)
+		dst = appendString(dst, value)
+	}
+	return append(dst, payload...)
+}
+
+// readRequestEnvelope decodes the binary request, all byte slices
+// and headers refer to the source data
+func readRequestEnvelope(data []byte, headers []header) (id []byte, deadline time.Time, timeout time.Duration, codec []byte, _ []header, payload []byte, err error) {
+	if id, data, err = readBytes(data); err != nil {
+		return
+	}
+	nsec, n := binary.Varint(data)
+	if n <= 0 {
+		err = errInvalidEnvelope
+		return
+	}
+	if data = data[n:]; nsec != 0 {
+		deadline = time.Unix(0, nsec)
+	}
+	if nsec, n = binary.Varint(data); n <= 0 {
+		err = errInvalidEnvelope
+		return
+	}
+	data, timeout = data[n:], time.Duration(nsec)
+	if codec, data, err = readBytes(data); err != nil {
+		return
+	}
+	if headers, data, err = readHeaders(data, headers); err != nil {
+		return
+	}
+	return id, deadline, timeout, codec, headers, data, nil
+}
+
+// Binary response envelope:
+//
+//	flags byte
+//	uvarint(count of headers) [uvarint(len(name)) name uvarint(len(value)) value]...
+//	payload or error up to the end
+func appendResponseEnvelope(dst []byte, flags byte, headers []header, payload []byte) []byte {
+	dst = append(dst, flags)
+	dst = binary.AppendUvarint(dst, uint64(len(headers)))
+	for _, h := range headers {
+		dst = appendBytes(dst, h.name)
+		dst = appendBytes(dst, h.value)
+	}
+	return append(dst, payload...)
+}
+
+// readResponseEnvelope decodes the binary response, all byte slices
+// and headers refer to the source data
+func readResponseEnvelope(data []byte, headers []header) (flags byte, _ []header, payload []byte, err error) {
+	if len(data) < 1 {
+		return 0, headers, nil, errInvalidEnvelope
+	}
+	flags = data[0]
+	if headers, data, err = readHeaders(data[1:], headers); err != nil {
+		return 0, headers, nil, err
+	}
+	return flags, headers, data, nil
+}
+
+func readHeaders(data []byte, headers []header) ([]header, []byte, error) {
+	count, n := binary.Uvarint(data)
+	if n <= 0 || count > uint64(len(data)) {
+		return headers, nil, errInvalidEnvelope
+	}
+	data = data[n:]
+	for i := uint64(0); i < count; i++ {
+		var (
+			h   header
+			err error
+		)
+		if h.name, data, err = readBytes(data); err != nil {
+			return headers, nil, err
+		}
+		canonicalHeaderKey(h.name)
+		if h.value, data, err = readBytes(data); err != nil {
+			return headers, nil, err
+		}
+		headers = append(headers, h)
+	}
+	return headers, data, nil
+}
+
+func readBytes(data []byte) (value, tail []byte, err error) {
+	size, n := binary.Uvarint(data)
+	if n <= 0 || size > uint64(len(data)-n) {
+		return nil, nil, errInvalidEnvelope
+	}
+	data = data[n:]
+	return data[:size:size], data[size:], nil
+}
+
+func appendString(dst []byte, s string) []byte {
+	dst = binary.AppendUvarint(dst, uint64(len(s)))
+	return append(dst, s...)
+}
+
+func appendBytes(dst, b []byte) []byte {
+	dst = binary.AppendUvarint(dst, uint64(len(b)))
+	return append(dst, b...)
+}
+
+// canonicalHeaderKey converts the key in place into the canonical format
+// like textproto.CanonicalMIMEHeaderKey does, keys with invalid bytes are kept
+func canonicalHeaderKey(key []byte) {
+	for _, c := range key {
+		if !isHeaderKeyByte(c) {
+			return
+		}
+	}
+	upper := true
+	for i, c := range key {
+		if upper && 'a' <= c && c <= 'z' {
+			c -= 'a' - 'A'
+		} else if !upper && 'A' <= c && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		key[i] = c
+		upper = c == '-'
+	}
+}
+
+func isHeaderKeyByte(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') ||
+		strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0
+}
+
+// headerValue returns value of the header by case insensitive name
+func headerValue(headers []header, name string) []byte {
+	name = textproto.CanonicalMIMEHeaderKey(name)
+	for _, h := range headers {
+		if string(h.name) == name {
+			return h.value
+		}
+	}
+	return nil
+}
+
+// headersMap converts headers list into the map
+func headersMap(headers []header, target map[string][]byte) map[string][]byte {
+	if len(headers) < 1 {
+		return target
+	}
+	if target == nil {
+		target = make(map[string][]byte, len(headers))
+	}
+	for _, h := range headers {
+		target[string(h.name)] = h.value
+	}
+	return target
+}
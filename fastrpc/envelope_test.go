@@ -0,0 +1,71 @@
+//
+// @project geniusrabbit::xrpc 2017
+// @author Dmitry Ponomarev <demdxx@gmail.com> 2017
+//
+
+package fastrpc
+
+import (
+	"net/textproto"
+	"testing"
+	"time"
+)
+
+func TestRequestEnvelope(t *testing.T) {
+	var (
+		deadline = time.Unix(0, time.Now().Add(time.Second).UnixNano())
+		data     = appendRequestEnvelope(nil, "id1", deadline, time.Second, "msgpack",
+			map[string]string{"X-Key": "value"}, []byte("payload"))
+	)
+
+	id, dl, timeout, codec, headers, payload, err := readRequestEnvelope(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(id) != "id1" || !dl.Equal(deadline) || timeout != time.Second || string(codec) != "msgpack" || string(payload) != "payload" {
+		t.Errorf("invalid request: %s %v %v %s %s", id, dl, timeout, codec, payload)
+	}
+	if string(headerValue(headers, "X-Key")) != "value" {
+		t.Errorf("invalid headers: %v", headersMap(headers, nil))
+	}
+
+	if _, dl, timeout, _, _, _, err = readRequestEnvelope(appendRequestEnvelope(nil, "", time.Time{}, 0, "", nil, nil), nil); err != nil || !dl.IsZero() || timeout != 0 {
+		t.Errorf("invalid empty request: %v %v %v", dl, timeout, err)
+	}
+
+	for i := 0; i < len(data)-len("payload"); i++ {
+		if _, _, _, _, _, _, err = readRequestEnvelope(data[:i], nil); err == nil {
+			t.Errorf("truncated request %d must be invalid", i)
+		}
+	}
+}
+
+func TestResponseEnvelope(t *testing.T) {
+	data := appendResponseEnvelope(nil, responseFlagError,
+		[]header{{name: []byte("X-Key"), value: []byte("value")}}, []byte("payload"))
+
+	flags, headers, payload, err := readResponseEnvelope(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if flags != responseFlagError || string(payload) != "payload" || string(headerValue(headers, "X-Key")) != "value" {
+		t.Errorf("invalid response: %d %s %v", flags, payload, headersMap(headers, nil))
+	}
+
+	if _, _, _, err = readResponseEnvelope(nil, nil); err == nil {
+		t.Error("empty response must be invalid")
+	}
+}
+
+func TestCanonicalHeaderKey(t *testing.T) {
+	for _, key := range []string{"x-key", "X-KEY", "x-request-USER-id", "content-type", "X_Key", "x key", "", "-x", "1-a"} {
+		name := []byte(key)
+		if canonicalHeaderKey(name); string(name) != textproto.CanonicalMIMEHeaderKey(key) {
+			t.Errorf("invalid canonical key of [%s]: %s", key, name)
+		}
+	}
+	headers := []header{{name: []byte("X-Key"), value: []byte("value")}}
+	if string(headerValue(headers, "x-KEY")) != "value" {
+		t.Error("header lookup must be case insensitive")
+	}
+}
@@ -0,0 +1,165 @@
+//
+// @project geniusrabbit::xrpc 2017
+// @author Dmitry Ponomarev <demdxx@gmail.com> 2017
+//
+
+package fastrpc
+
+import (
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// handshakeTimeout of reading the protocol version of the client
+var handshakeTimeout = 3 * time.Second
+
+// protocolMux routes accepted connections to the listeners of the protocol
+// version announced by the client handshake, so clients of the different
+// versions could be served by the same address
+type protocolMux struct {
+	listener    net.Listener
+	sniffHeader string
+	listeners   map[byte]*protocolListener
+
+	mx    sync.Mutex
+	conns map[*prefixConn]struct{}
+}
+
+func newProtocolMux(listener net.Listener, sniffHeader string, versions ...byte) *protocolMux {
+	mux := &protocolMux{
+		listener:    listener,
+		sniffHeader: sniffHeader,
+		listeners:   make(map[byte]*protocolListener, len(versions)),
+		conns:       map[*prefixConn]struct{}{},
+	}
+	for _, version := range versions {
+		mux.listeners[version] = &protocolListener{
+			addr:  listener.Addr(),
+			conns: make(chan net.Conn),
+			done:  make(chan struct{}),
+		}
+	}
+	return mux
+}
+
+// listenerOf the protocol version
+func (m *protocolMux) listenerOf(version byte) net.Listener {
+	return m.listeners[version]
+}
+
+// serve accepts connections until the listener is closed,
+// all protocol listeners are closed after that
+func (m *protocolMux) serve() error {
+	defer m.close()
+	for {
+		conn, err := m.listener.Accept()
+		if err != nil {
+			return err
+		}
+		go m.route(conn)
+	}
+}
+
+// route connection by the protocol version, the handshake prefix
+// is replayed to the server of the version
+func (m *protocolMux) route(conn net.Conn) {
+	prefix := make([]byte, len(m.sniffHeader)+1)
+	_ = conn.SetReadDeadline(time.Now().Add(handshakeTimeout))
+	if _, err := io.ReadFull(conn, prefix); err != nil || string(prefix[:len(m.sniffHeader)]) != m.sniffHeader {
+		_ = conn.Close()
+		return
+	}
+	_ = conn.SetReadDeadline(time.Time{})
+
+	listener := m.listeners[prefix[len(m.sniffHeader)]]
+	if listener == nil {
+		_ = conn.Close()
+		return
+	}
+	pconn := &prefixConn{Conn: conn, prefix: prefix, mux: m}
+	m.mx.Lock()
+	m.conns[pconn] = struct{}{}
+	m.mx.Unlock()
+
+	select {
+	case listener.conns <- pconn:
+	case <-listener.done:
+		_ = pconn.Close()
+	}
+}
+
+func (m *protocolMux) close() {
+	for _, listener := range m.listeners {
+		_ = listener.Close()
+	}
+}
+
+// closeConns closes all routed connections
+func (m *protocolMux) closeConns() {
+	m.mx.Lock()
+	conns := m.conns
+	m.conns = map[*prefixConn]struct{}{}
+	m.mx.Unlock()
+	for conn := range conns {
+		_ = conn.Conn.Close()
+	}
+}
+
+// protocolListener accepts connections of the particular protocol version
+type protocolListener struct {
+	addr  net.Addr
+	conns chan net.Conn
+	done  chan struct{}
+	once  sync.Once
+}
+
+// Accept waits for and returns the next connection to the listener
+func (l *protocolListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close the listener
+func (l *protocolListener) Close() error {
+	l.once.Do(func() { close(l.done) })
+	return nil
+}
+
+// Addr returns the listener's network address
+func (l *protocolListener) Addr() net.Addr {
+	return l.addr
+}
+
+// prefixConn replays already read prefix before reading the connection
+type prefixConn struct {
+	net.Conn
+	prefix []byte
+	mux    *protocolMux
+}
+
+func (c *prefixConn) Read(b []byte) (int, error) {
+	if len(c.prefix) > 0 {
+		n := copy(b, c.prefix)
+		c.prefix = c.prefix[n:]
+		return n, nil
+	}
+	return c.Conn.Read(b)
+}
+
+// NetConn returns the underlying connection
+func (c *prefixConn) NetConn() net.Conn {
+	return c.Conn
+}
+
+func (c *prefixConn) Close() error {
+	c.mux.mx.Lock()
+	delete(c.mux.conns, c)
+	c.mux.mx.Unlock()
+	return c.Conn.Close()
+}
@@ -0,0 +1,53 @@
+//
+// @project geniusrabbit::xrpc 2017
+// @author Dmitry Ponomarev <demdxx@gmail.com> 2017
+//
+
+package fastrpc
+
+import (
+	"io"
+	"net"
+	"testing"
+)
+
+func TestProtocolMux(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+
+	mux := newProtocolMux(ln, "fastrpc", ProtocolVersion, ProtocolVersionJSON)
+	done := make(chan error, 1)
+	go func() { done <- mux.serve() }()
+
+	for _, version := range []byte{ProtocolVersionJSON, ProtocolVersion} {
+		conn, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		handshake := append([]byte("fastrpc"), version, 0)
+		if _, err = conn.Write(handshake); err != nil {
+			t.Fatal(err)
+		}
+
+		accepted, err := mux.listenerOf(version).Accept()
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf := make([]byte, len(handshake))
+		if _, err = io.ReadFull(accepted, buf); err != nil || string(buf) != string(handshake) {
+			t.Errorf("invalid handshake replay: %q %v", buf, err)
+		}
+		_ = accepted.Close()
+		_ = conn.Close()
+	}
+
+	_ = ln.Close()
+	if <-done == nil {
+		t.Error("serve must return the listener error")
+	}
+	if _, err = mux.listenerOf(ProtocolVersion).Accept(); err != net.ErrClosed {
+		t.Errorf("listener must be closed: %v", err)
+	}
+}
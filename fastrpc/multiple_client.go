@@ -6,6 +6,7 @@
 package fastrpc
 
 import (
+	"context"
 	"crypto/tls"
 	"net"
 	"sync"
@@ -61,6 +62,9 @@ type connAddr struct {
 	//
 	// fasthttp.Dial is used by default.
 	Dial func(addr string) (net.Conn, error)
+
+	// TLSConfig of the secure address
+	TLSConfig *tls.Config
 }
 
 func (c connAddr) GetDial(dial func(addr string) (net.Conn, error)) func(addr string) (net.Conn, error) {
@@ -70,6 +74,13 @@ func (c connAddr) GetDial(dial func(addr string) (net.Conn, error)) func(addr st
 	return c.Dial
 }
 
+func (c connAddr) GetTLSConfig(config *tls.Config) *tls.Config {
+	if config != nil {
+		return config
+	}
+	return c.TLSConfig
+}
+
 // MultipleClient implementation
 type MultipleClient struct {
 	// SniffHeader is the header written to each connection established
@@ -82,6 +93,8 @@ type MultipleClient struct {
 	//
 	// The ProtocolVersion must be changed each time RequestWriter
 	// or ResponseReader changes the underlying format.
+	//
+	// ProtocolVersionJSON is used for the servers without binary envelope.
 	ProtocolVersion byte
 
 	// Addrs list of the Server address to connect to
@@ -98,13 +111,24 @@ type MultipleClient struct {
 	Dial func(addr string) (net.Conn, error)
 
 	// TLSConfig is TLS (aka SSL) config used for establishing encrypted
-	// connection to the server.
+	// connection to the server after the protocol handshake, the server
+	// must be configured by fastrpc.Server.TLSConfig as well.
+	//
+	// By default connection to the server isn't encrypted.
+	//
+	// Deprecated: servers of this package encrypt the whole connection,
+	// use ConnTLSConfig to connect them.
+	TLSConfig *tls.Config
+
+	// ConnTLSConfig is TLS config of the encrypted connection to the server.
+	// The whole connection including the protocol handshake is encrypted.
 	//
 	// Encrypted connections may be used for transferring sensitive
 	// information over untrusted networks.
 	//
-	// By default connection to the server isn't encrypted.
-	TLSConfig *tls.Config
+	// By default connection to the server isn't encrypted
+	// unless the address has TLS scheme.
+	ConnTLSConfig *tls.Config
 
 	// MaxPendingRequests is the maximum number of pending requests
 	// the client may issue until the server responds to them.
@@ -146,12 +170,18 @@ type MultipleClient struct {
 	// requests is reached.
 	PrioritizeNewRequests bool
 
+	// Codec of the messages encoding, DefaultCodec is used by default
+	Codec xrpc.Codec
+
 	// clients pull of fastrpc clients
 	clients []*fastrpc.Client
 
 	// clientRoundRobinIndex it's offset counter
 	clientRoundRobinIndex int32
 
+	// err of the addresses parsing
+	err error
+
 	mx sync.Mutex
 }
 
@@ -159,11 +189,12 @@ type MultipleClient struct {
 func NewMultipleClient(clintsCount int, addr string, addrs ...string) xrpc.Client {
 	cli := &MultipleClient{
 		SniffHeader:           "fastrpc",
-		ProtocolVersion:       0,
+		ProtocolVersion:       ProtocolVersion,
 		Addrs:                 nil,
 		CompressType:          CompressNone,
 		Dial:                  nil,
 		TLSConfig:             nil,
+		ConnTLSConfig:         nil,
 		MaxPendingRequests:    0,
 		MaxBatchDelay:         0,
 		ReadTimeout:           0 * time.Millisecond,
@@ -179,21 +210,43 @@ func NewMultipleClient(clintsCount int, addr string, addrs ...string) xrpc.Clien
 
 // Send message to service
 func (c *MultipleClient) Send(msg xrpc.Message) xrpc.Response {
-	return sendMessage(c.client(), msg)
+	if c.err != nil {
+		return &Response{err: c.err}
+	}
+	return sendMessage(context.Background(), c.client(), c.Codec, msg)
+}
+
+// SendContext message to service with cancellation and deadline of the context
+func (c *MultipleClient) SendContext(ctx context.Context, msg xrpc.Message) xrpc.Response {
+	if c.err != nil {
+		return &Response{err: c.err}
+	}
+	return sendMessage(ctx, c.client(), c.Codec, msg)
 }
 
 // SendBatch of messages
 func (c *MultipleClient) SendBatch(msgs ...xrpc.Message) <-chan xrpc.Response {
-	var (
-		ch     = make(chan xrpc.Response, len(msgs))
-		client = c.client()
-	)
+	return c.SendBatchContext(context.Background(), msgs...)
+}
+
+// SendBatchContext of messages with cancellation and deadline of the context
+func (c *MultipleClient) SendBatchContext(ctx context.Context, msgs ...xrpc.Message) <-chan xrpc.Response {
+	var ch = make(chan xrpc.Response, len(msgs))
+	if c.err != nil {
+		for range msgs {
+			ch <- &Response{err: c.err}
+		}
+		close(ch)
+		return ch
+	}
+
+	var client = c.client()
 	go func() {
 		var wg sync.WaitGroup
 		wg.Add(len(msgs))
 		for _, msg := range msgs {
 			go func(msg xrpc.Message) {
-				ch <- sendMessage(client, msg)
+				ch <- sendMessage(ctx, client, c.Codec, msg)
 				wg.Done()
 			}(msg)
 		}
@@ -204,18 +257,14 @@ func (c *MultipleClient) SendBatch(msgs ...xrpc.Message) <-chan xrpc.Response {
 }
 
 func (c *MultipleClient) setAddrs(addr string, addrs ...string) {
-	var (
-		naddr, dial = dialer(addr)
-		cons        = []connAddr{
-			{Addr: naddr, Dial: dial},
+	var cons = make([]connAddr, 0, len(addrs)+1)
+	for _, addr := range append([]string{addr}, addrs...) {
+		con, err := dialer(addr)
+		if err != nil && c.err == nil {
+			c.err = err
 		}
-	)
-
-	for _, addr := range addrs {
-		naddr, dial = dialer(addr)
-		cons = append(cons, connAddr{Addr: naddr, Dial: dial})
+		cons = append(cons, con)
 	}
-
 	c.Addrs = cons
 }
 
@@ -234,7 +283,7 @@ func (c *MultipleClient) client() (cli *fastrpc.Client) {
 				NewResponse:           func() fastrpc.ResponseReader { return &tlv.Response{} },
 				Addr:                  con.Addr,
 				CompressType:          fastrpc.CompressType(c.CompressType),
-				Dial:                  con.GetDial(c.Dial),
+				Dial:                  tlsDial(con.GetDial(c.Dial), con.GetTLSConfig(c.ConnTLSConfig)),
 				TLSConfig:             c.TLSConfig,
 				MaxPendingRequests:    c.MaxPendingRequests,
 				MaxBatchDelay:         c.MaxBatchDelay,
@@ -6,30 +6,58 @@
 package fastrpc
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"time"
 
+	"github.com/geniusrabbit/xrpc"
 	"github.com/valyala/fastrpc/tlv"
 )
 
 type message struct {
-	ID      string            `json:"id"`
-	Timeout time.Duration     `json:"timeout"`
-	Headers map[string][]byte `json:"headers"`
-	Data    json.RawMessage   `json:"data"`
+	ID       string            `json:"id"`
+	Timeout  time.Duration     `json:"timeout"`
+	Deadline int64             `json:"deadline,omitempty"` // unix nanoseconds
+	Headers  map[string]string `json:"headers"`
+	Codec    string            `json:"codec,omitempty"`
+	Data     json.RawMessage   `json:"data"`
+
+	// Payload of the data encoded by not JSON codec
+	Payload []byte `json:"payload,omitempty"`
+
+	// Envelope is requested by the client which accepts response headers
+	Envelope bool `json:"envelope,omitempty"`
 }
 
 type request struct {
-	msg    message
-	ctx    context.Context
-	reqCtx *tlv.RequestCtx
+	version  byte
+	id       []byte
+	timeout  time.Duration
+	deadline time.Time
+	pairs    []header
+	headers  map[string][]byte
+	data     []byte
+	codec    xrpc.Codec
+	ctx      context.Context
+	reqCtx   *tlv.RequestCtx
+	params   xrpc.Params
+
+	// msg of the legacy JSON envelope
+	msg message
+
+	// envelope is true if the client accepts the response with headers
+	envelope    bool
+	respHeaders []header
+	respData    []byte
+
+	// buf of the response envelope
+	buf []byte
 }
 
 // ID of request
 func (r *request) ID() []byte {
-	return []byte(r.msg.ID)
+	return r.id
 }
 
 // Action name
@@ -39,7 +67,22 @@ func (r *request) Action() []byte {
 
 // Timeout value
 func (r *request) Timeout() time.Duration {
-	return r.msg.Timeout
+	return r.timeout
+}
+
+// Params captured by the action path pattern
+func (r *request) Params() xrpc.Params {
+	return r.params
+}
+
+// SetParams of the request, the params buffer is reused by the next request
+func (r *request) SetParams(params xrpc.Params) {
+	r.params = params
+}
+
+// Peer information of the request connection
+func (r *request) Peer() xrpc.Peer {
+	return xrpc.PeerOf(TransportName, r.reqCtx.Conn())
 }
 
 // External Context
@@ -60,29 +103,135 @@ func (r *request) Source() interface{} {
 	return r.reqCtx
 }
 
-// Headers from request
+// Header value of the request by name
+func (r *request) Header(name string) []byte {
+	return headerValue(r.pairs, name)
+}
+
+// Headers from request, the map is filled on the first call
 func (r *request) Headers() map[string][]byte {
-	return r.msg.Headers
+	if len(r.headers) == 0 {
+		r.headers = headersMap(r.pairs, r.headers)
+	}
+	return r.headers
+}
+
+// SetHeader of the response, the name is canonicalized. Headers are dropped
+// for legacy clients which don't accept the response envelope
+func (r *request) SetHeader(name string, value []byte) {
+	n := len(r.respHeaders)
+	if n < cap(r.respHeaders) {
+		r.respHeaders = r.respHeaders[:n+1]
+	} else {
+		r.respHeaders = append(r.respHeaders, header{})
+	}
+	r.respHeaders[n].name = append(r.respHeaders[n].name[:0], name...)
+	canonicalHeaderKey(r.respHeaders[n].name)
+	r.respHeaders[n].value = append(r.respHeaders[n].value[:0], value...)
 }
 
 // Bind message to object or structure
 func (r *request) Bind(target interface{}) error {
-	if r.msg.Data == nil {
-		if err := json.Unmarshal(r.reqCtx.Request.Value(), &r.msg); err != nil {
-			return err
+	return r.codec.Unmarshal(r.data, target)
+}
+
+// decode message envelope from the source request
+func (r *request) decode(version byte, defaultCodec xrpc.Codec) (err error) {
+	var (
+		id, codec []byte
+		deadline  time.Time
+	)
+	if r.version = version; version == ProtocolVersionJSON {
+		deadline, codec, err = r.decodeJSON()
+	} else {
+		r.envelope = true
+		id, deadline, r.timeout, codec, r.pairs, r.data, err = readRequestEnvelope(r.reqCtx.Request.Value(), r.pairs[:0])
+		r.id = append(r.id[:0], id...)
+	}
+	if err != nil {
+		return err
+	}
+	if r.deadline = requestDeadline(deadline, r.timeout); !r.deadline.IsZero() {
+		if r.timeout = time.Until(r.deadline); r.timeout <= 0 {
+			// Keep the timeout positive to mark the request as limited
+			r.timeout = 1
 		}
 	}
-	return json.Unmarshal(r.msg.Data, target)
+	if len(codec) == 0 {
+		r.codec = defaultCodec
+	} else if r.codec = xrpc.CodecByName(string(codec)); r.codec == nil {
+		return fmt.Errorf("unsupported codec %s", codec)
+	}
+	return nil
 }
 
-// Send message as response
-func (r *request) Send(msg interface{}) (err error) {
-	var buff bytes.Buffer
+// decodeJSON message of the legacy envelope
+func (r *request) decodeJSON() (deadline time.Time, _ []byte, err error) {
+	if err = json.Unmarshal(r.reqCtx.Request.Value(), &r.msg); err != nil {
+		return deadline, nil, err
+	}
+	r.id = append(r.id[:0], r.msg.ID...)
+	r.timeout = r.msg.Timeout
+	if r.msg.Deadline != 0 {
+		deadline = time.Unix(0, r.msg.Deadline)
+	}
+	for name, value := range r.msg.Headers {
+		h := header{name: []byte(name), value: []byte(value)}
+		canonicalHeaderKey(h.name)
+		r.pairs = append(r.pairs, h)
+	}
+	if r.data = r.msg.Data; len(r.data) == 0 {
+		r.data = r.msg.Payload
+	}
+	r.envelope = r.msg.Envelope
+	return deadline, []byte(r.msg.Codec), nil
+}
 
-	if err = json.NewEncoder(&buff).Encode(msg); err != nil {
-		return err
+// requestDeadline returns the absolute deadline of the client limited
+// by the timeout counted from now. The deadline includes the time spent
+// by the request on the way if clocks of peers are synchronized, otherwise
+// the limit prevents the request from being stretched by the clock skew.
+func requestDeadline(deadline time.Time, timeout time.Duration) time.Time {
+	if timeout > 0 {
+		if limit := time.Now().Add(timeout); deadline.IsZero() || deadline.After(limit) {
+			return limit
+		}
 	}
+	return deadline
+}
 
-	_, err = r.reqCtx.Write(buff.Bytes())
+// Send message as response
+func (r *request) Send(msg interface{}) error {
+	data, err := r.codec.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if r.envelope {
+		r.respData = data
+		return nil
+	}
+	_, err = r.reqCtx.Write(data)
 	return err
 }
+
+// reset request state, allocated buffers are kept for the next request
+func (r *request) reset() {
+	for key := range r.msg.Headers {
+		delete(r.msg.Headers, key)
+	}
+	for key := range r.headers {
+		delete(r.headers, key)
+	}
+	for i := range r.params {
+		r.params[i] = xrpc.Param{}
+	}
+	*r = request{
+		id:          r.id[:0],
+		pairs:       r.pairs[:0],
+		headers:     r.headers,
+		params:      r.params[:0],
+		msg:         message{Headers: r.msg.Headers, Data: r.msg.Data[:0]},
+		respHeaders: r.respHeaders[:0],
+		buf:         r.buf[:0],
+	}
+}
@@ -7,49 +7,120 @@ package fastrpc
 
 import (
 	"encoding/json"
-	"errors"
 
 	"github.com/geniusrabbit/xrpc"
 	"github.com/valyala/fastrpc/tlv"
 )
 
+// responseMessage is the legacy response envelope with headers of the response,
+// the envelope mark distinguishes it from the action data replied as is
+// to clients which don't request the envelope and by servers of previous releases
+type responseMessage struct {
+	Envelope bool              `json:"envelope"`
+	Headers  map[string]string `json:"headers,omitempty"`
+	Error    json.RawMessage   `json:"error,omitempty"`
+	Data     json.RawMessage   `json:"data,omitempty"`
+
+	// Payload of the data encoded by not JSON codec
+	Payload []byte `json:"payload,omitempty"`
+}
+
 // Response wrapper
 type Response struct {
-	parsedError bool
-	resp        *tlv.Response
-	err         error
+	parsed  bool
+	version byte
+	headers []header
+	data    []byte
+	resp    *tlv.Response
+	codec   xrpc.Codec
+	err     error
 }
 
 // Source of request used for processing this methods
-func (r Response) Source() interface{} {
+func (r *Response) Source() interface{} {
 	return r.resp
 }
 
 // Bind message to object or structure
-func (r Response) Bind(target interface{}) error {
-	if r.err != nil {
+func (r *Response) Bind(target interface{}) error {
+	if r.Error() != nil {
 		return r.err
 	}
 	if r.resp == nil {
 		return xrpc.ErrInvalidResponse
 	}
-	return json.Unmarshal(r.resp.Value(), target)
+	return xrpc.CodecOrDefault(r.codec).Unmarshal(r.data, target)
+}
+
+// Header value of the response by name
+func (r *Response) Header(name string) []byte {
+	r.parse()
+	return headerValue(r.headers, name)
+}
+
+// Headers of the response
+func (r *Response) Headers() map[string][]byte {
+	r.parse()
+	return headersMap(r.headers, nil)
 }
 
 // Error response
 func (r *Response) Error() error {
-	if r.err == nil && r.resp != nil {
-		var err struct {
-			Error string `json:"error"`
+	r.parse()
+	return r.err
+}
+
+// Release the response into the pool
+func (r *Response) Release() {
+	if r.resp != nil {
+		tlv.ReleaseResponse(r.resp)
+		r.resp = nil
+	}
+}
+
+// parse response value once
+func (r *Response) parse() {
+	if r.parsed || r.err != nil || r.resp == nil {
+		return
+	}
+	r.parsed = true
+
+	if r.version == ProtocolVersionJSON {
+		r.parseJSON(r.resp.Value())
+		return
+	}
+
+	var flags byte
+	flags, r.headers, r.data, r.err = readResponseEnvelope(r.resp.Value(), r.headers[:0])
+	if r.err == nil && flags&responseFlagError != 0 {
+		if r.err = xrpc.UnmarshalError(r.data); r.err == nil {
+			r.err = xrpc.ErrInvalidResponse
+		}
+	}
+}
+
+// parseJSON response of the legacy envelope, the response without
+// the envelope mark contains the action data or the error as is
+func (r *Response) parseJSON(value []byte) {
+	var msg responseMessage
+	if json.Unmarshal(value, &msg) != nil || !msg.Envelope {
+		if r.err = xrpc.UnmarshalError(value); r.err == nil {
+			r.data = value
 		}
-		if e := json.Unmarshal(r.resp.Value(), &err); e == nil {
-			if err.Error == "action not found" {
-				r.err = xrpc.ErrActionNotFound
-			} else if err.Error != "" {
-				r.err = errors.New(err.Error)
-			}
+		return
+	}
+	if len(msg.Error) > 0 {
+		if r.err = xrpc.UnmarshalError(msg.Error); r.err == nil {
+			r.err = xrpc.ErrInvalidResponse
 		}
-		r.parsedError = true
+		return
+	}
+	for name, value := range msg.Headers {
+		h := header{name: []byte(name), value: []byte(value)}
+		canonicalHeaderKey(h.name)
+		r.headers = append(r.headers, h)
+	}
+	if r.data = msg.Data; len(r.data) == 0 {
+		r.data = msg.Payload
 	}
-	return r.err
 }
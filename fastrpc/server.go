@@ -7,10 +7,13 @@ package fastrpc
 
 import (
 	"context"
+	"crypto/tls"
+	"encoding/json"
 	"fmt"
 	"net"
-	"net/url"
-	"strings"
+	"runtime/debug"
+	"sync"
+	"time"
 
 	"github.com/geniusrabbit/xrpc"
 	"github.com/valyala/fastrpc"
@@ -18,104 +21,352 @@ import (
 	"github.com/valyala/tcplisten"
 )
 
+// TransportName of the requests peer
+const TransportName = "fastrpc"
+
+const defaultConcurrency = 100
+
+// ServerOption of the server configuration
+type ServerOption func(srv *server)
+
+// WithCodec defines the codec used for requests without codec name
+func WithCodec(codec xrpc.Codec) ServerOption {
+	return func(srv *server) {
+		srv.codec = codec
+	}
+}
+
+// WithResponseCompress defines the compression of responses
+func WithResponseCompress(compress CompressType) ServerOption {
+	return func(srv *server) {
+		srv.rpc.CompressType = fastrpc.CompressType(compress)
+		srv.legacyRPC.CompressType = fastrpc.CompressType(compress)
+	}
+}
+
+// WithConcurrency limits the number of concurrently processed requests,
+// the limit is shared by clients of all protocol versions
+func WithConcurrency(concurrency int) ServerOption {
+	return func(srv *server) {
+		srv.concurrency = concurrency
+		srv.rpc.Concurrency = concurrency
+		srv.legacyRPC.Concurrency = concurrency
+	}
+}
+
+// WithTLS serves all connections of the server by TLS, client certificates
+// are verified if it's required by the configuration (mutual TLS).
+// The whole connection including the protocol handshake is encrypted.
+func WithTLS(config *tls.Config) ServerOption {
+	return func(srv *server) {
+		srv.tlsConfig = config
+	}
+}
+
+// WithPanicReporter defines reporter of panics recovered by the server,
+// it receives panics of actions only if the service recovery is disabled
+// by xrpc.WithRecovery(false). By default panics are written to the standard logger.
+func WithPanicReporter(reporter xrpc.PanicReporter) ServerOption {
+	return func(srv *server) {
+		srv.panicReporter = reporter
+	}
+}
+
 type server struct {
-	service xrpc.Service
-	rpc     fastrpc.Server
-}
-
-// NewServer default configurated server
-func NewServer(service xrpc.Service) xrpc.Server {
-	return &server{
-		service: service,
-		rpc: fastrpc.Server{
-			SniffHeader:      "fastrpc",
-			ProtocolVersion:  0,
-			NewHandlerCtx:    newHandlerCtx,
-			Handler:          nil,
-			CompressType:     fastrpc.CompressNone,
-			Concurrency:      100,
-			MaxBatchDelay:    0,
-			ReadTimeout:      0,
-			WriteTimeout:     0,
-			ReadBufferSize:   10 * 1024,
-			WriteBufferSize:  10 * 1024,
-			PipelineRequests: false,
-		},
+	service   xrpc.Service
+	codec     xrpc.Codec
+	tlsConfig *tls.Config
+
+	// rpc serves clients of the binary envelope,
+	// legacyRPC serves clients of the JSON envelope
+	rpc       *fastrpc.Server
+	legacyRPC *fastrpc.Server
+
+	// limiter of concurrently processed requests of both servers
+	concurrency int
+	limiter     chan struct{}
+
+	inflight xrpc.Inflight
+	mx       sync.Mutex
+	muxes    map[*protocolMux]struct{}
+
+	panicReporter xrpc.PanicReporter
+}
+
+// NewServer default configurated server which accepts clients
+// of all supported protocol versions
+func NewServer(service xrpc.Service, opts ...ServerOption) xrpc.Server {
+	srv := &server{service: service, concurrency: defaultConcurrency, panicReporter: xrpc.LogPanicReporter}
+	srv.rpc = newRPCServer(ProtocolVersion, srv.handler)
+	srv.legacyRPC = newRPCServer(ProtocolVersionJSON, srv.legacyHandler)
+	for _, opt := range opts {
+		opt(srv)
+	}
+	if srv.concurrency > 0 {
+		srv.limiter = make(chan struct{}, srv.concurrency)
+	}
+	return srv
+}
+
+func newRPCServer(version byte, handler func(ctx fastrpc.HandlerCtx) fastrpc.HandlerCtx) *fastrpc.Server {
+	return &fastrpc.Server{
+		SniffHeader:      "fastrpc",
+		ProtocolVersion:  version,
+		NewHandlerCtx:    newHandlerCtx(version),
+		Handler:          handler,
+		CompressType:     fastrpc.CompressNone,
+		Concurrency:      defaultConcurrency,
+		MaxBatchDelay:    0,
+		ReadTimeout:      0,
+		WriteTimeout:     0,
+		ReadBufferSize:   10 * 1024,
+		WriteBufferSize:  10 * 1024,
+		PipelineRequests: false,
 	}
 }
 
 // Listen some address which could be any connection type like:
-// tcp://hostname:port or udp://... or unix://... etc.
+// tcp://hostname:port or unix:///path or host:port etc.
 func (s *server) Listen(address string) error {
-	var (
-		u, err   = url.Parse(address)
-		listener net.Listener
-	)
-
+	addr, err := xrpc.ParseAddress(address)
+	if err != nil {
+		return err
+	}
+	if addr.TLS && s.tlsConfig == nil {
+		return fmt.Errorf("TLS configuration is required to listen [%s]", address)
+	}
+	listener, err := listen(addr)
 	if err != nil {
 		return err
 	}
+	defer listener.Close()
+	return s.Serve(listener)
+}
 
-	switch u.Scheme {
+// listen the address, TCP addresses are listened by tcplisten
+// and the tcp network is listened as tcp4
+func listen(addr xrpc.Address) (net.Listener, error) {
+	switch addr.Network {
 	case "tcp", "tcp4", "tcp6":
-		var cfg = tcplisten.Config{ReusePort: false}
-		if u.Scheme == "tcp" {
-			u.Scheme = "tcp4"
+		var (
+			cfg     = tcplisten.Config{ReusePort: false}
+			network = addr.Network
+		)
+		if network == "tcp" {
+			network = "tcp4"
 		}
-		if listener, err = cfg.NewListener(u.Scheme, u.Host); err != nil {
-			return err
-		}
-		defer listener.Close()
-	case "unix", "unixpacket":
-		if listener, err = net.Listen(u.Scheme, u.Host); err != nil {
-			return err
-		}
-		defer listener.Close()
-	default:
-		return fmt.Errorf("connection type [%s] not supported", u.Scheme)
+		return cfg.NewListener(network, addr.Address)
 	}
-
-	s.rpc.Handler = s.handler
-	return s.rpc.Serve(listener)
+	return addr.Listen()
 }
 
-func (s *server) handler(tctx fastrpc.HandlerCtx) fastrpc.HandlerCtx {
+// Serve connections of the listener by the server of the protocol
+// version requested by the client, connections are wrapped by TLS
+// if the server is configured with it
+func (s *server) Serve(listener net.Listener) error {
+	if s.tlsConfig != nil {
+		listener = tls.NewListener(listener, s.tlsConfig)
+	}
 	var (
-		ctx = tctx.(*tlv.RequestCtx)
-		req = request{
-			ctx:    s.requestCtx(ctx),
-			reqCtx: ctx,
-		}
+		mux  = newProtocolMux(listener, s.rpc.SniffHeader, ProtocolVersion, ProtocolVersionJSON)
+		errs = make(chan error, 2)
 	)
 
-	if err := s.service.Handle(&req); err != nil {
-		if err == xrpc.ErrActionNotFound {
-			s.handlerNotFound(ctx)
-		} else {
-			s.handlerError(ctx, err)
-		}
+	s.mx.Lock()
+	if s.inflight.IsClosed() {
+		s.mx.Unlock()
+		return xrpc.ErrServerClosed
+	}
+	if s.muxes == nil {
+		s.muxes = map[*protocolMux]struct{}{}
+	}
+	s.muxes[mux] = struct{}{}
+	s.mx.Unlock()
+
+	go func() { errs <- s.rpc.Serve(mux.listenerOf(ProtocolVersion)) }()
+	go func() { errs <- s.legacyRPC.Serve(mux.listenerOf(ProtocolVersionJSON)) }()
+	err := mux.serve()
+	<-errs
+	<-errs
+
+	s.mx.Lock()
+	delete(s.muxes, mux)
+	s.mx.Unlock()
+
+	if s.inflight.IsClosed() {
+		return nil
+	}
+	return err
+}
+
+// Shutdown the server gracefully, connections are closed
+// after in-flight requests are finished or the context is done
+func (s *server) Shutdown(ctx context.Context) error {
+	s.mx.Lock()
+	s.inflight.Close()
+	muxes := make([]*protocolMux, 0, len(s.muxes))
+	for mux := range s.muxes {
+		muxes = append(muxes, mux)
+	}
+	s.mx.Unlock()
+
+	for _, mux := range muxes {
+		_ = mux.listener.Close()
+	}
+	err := s.inflight.Wait(ctx)
+	for _, mux := range muxes {
+		mux.closeConns()
+	}
+	return err
+}
+
+func (s *server) handler(tctx fastrpc.HandlerCtx) fastrpc.HandlerCtx {
+	return s.handle(tctx.(*tlv.RequestCtx), ProtocolVersion)
+}
+
+func (s *server) legacyHandler(tctx fastrpc.HandlerCtx) fastrpc.HandlerCtx {
+	return s.handle(tctx.(*tlv.RequestCtx), ProtocolVersionJSON)
+}
+
+func (s *server) handle(ctx *tlv.RequestCtx, version byte) fastrpc.HandlerCtx {
+	if !s.inflight.Begin() {
+		handlerError(ctx, version, xrpc.ErrServerClosed)
+		return ctx
+	}
+	defer s.inflight.Done()
+
+	if !s.acquire() {
+		handlerError(ctx, version, xrpc.ErrOverloaded)
+		return ctx
+	}
+	defer s.release()
+
+	req := acquireRequest()
+	defer releaseRequest(req)
+	defer s.recoverPanic(ctx, req, version)
+	req.reqCtx = ctx
+
+	if err := req.decode(version, xrpc.CodecOrDefault(s.codec)); err != nil {
+		handlerError(ctx, version, xrpc.NewError(xrpc.CodeInvalidArgument, err.Error()))
+		return ctx
+	}
+
+	reqCtx, cancel := s.requestCtx(req.deadline)
+	defer cancel()
+
+	// Skip processing of the request which caller already gave up
+	if reqCtx.Err() != nil {
+		s.writeResponse(ctx, req, xrpc.ErrDeadlineExceeded)
+		return ctx
 	}
+
+	req.ctx = reqCtx
+	s.writeResponse(ctx, req, s.service.Handle(req))
 	return ctx
 }
 
-func (s *server) requestCtx(ctx fastrpc.HandlerCtx) context.Context {
-	return context.Background()
+// acquire the slot of the concurrency limit without waiting
+func (s *server) acquire() bool {
+	if s.limiter == nil {
+		return true
+	}
+	select {
+	case s.limiter <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *server) release() {
+	if s.limiter != nil {
+		<-s.limiter
+	}
+}
+
+// recoverPanic replies with the internal error, the server is always
+// protected whether the recovery of the service is disabled or not
+func (s *server) recoverPanic(ctx *tlv.RequestCtx, req *request, version byte) {
+	if rec := recover(); rec != nil {
+		if s.panicReporter != nil {
+			s.panicReporter(req, rec, debug.Stack())
+		}
+		handlerError(ctx, version, xrpc.ErrInternal)
+	}
+}
+
+// writeResponse of the action, clients which accept the envelope
+// receive the response headers along with the data
+func (s *server) writeResponse(ctx *tlv.RequestCtx, req *request, err error) {
+	switch {
+	case req.version != ProtocolVersionJSON:
+		var (
+			flags   byte
+			payload = req.respData
+		)
+		if err != nil {
+			flags, payload = responseFlagError, xrpc.MarshalError(err)
+		} else if payload == nil {
+			// Data could be written to the source request directly
+			payload = ctx.Response.Value()
+		}
+		req.buf = appendResponseEnvelope(req.buf[:0], flags, req.respHeaders, payload)
+		ctx.Response.SetValue(req.buf)
+	case req.envelope:
+		s.writeJSONResponse(ctx, req, err)
+	case err != nil:
+		handlerError(ctx, req.version, err)
+	}
 }
 
-func (s *server) handlerNotFound(ctx *tlv.RequestCtx) {
-	ctx.Response.SwapValue([]byte(`{"error":"not found"}`))
+// writeJSONResponse of the legacy envelope
+func (s *server) writeJSONResponse(ctx *tlv.RequestCtx, req *request, err error) {
+	envelope := responseMessage{Envelope: true}
+	if len(req.respHeaders) > 0 {
+		envelope.Headers = make(map[string]string, len(req.respHeaders))
+		for _, h := range req.respHeaders {
+			envelope.Headers[string(h.name)] = string(h.value)
+		}
+	}
+	switch {
+	case err != nil:
+		envelope.Error = xrpc.MarshalError(err)
+	case req.codec.Name() == xrpc.JSONCodec.Name():
+		envelope.Data = req.respData
+	default:
+		envelope.Payload = req.respData
+	}
+
+	data, err := json.Marshal(&envelope)
+	if err != nil {
+		handlerError(ctx, req.version, err)
+		return
+	}
+	ctx.Response.SwapValue(data)
 }
 
-func (s *server) handlerError(ctx *tlv.RequestCtx, err error) {
-	ctx.Response.SwapValue([]byte(`{"error":"` + strings.Replace("\"", "\\\"", err.Error(), -1) + `"}`))
+// requestCtx returns the context limited by the propagated deadline
+func (s *server) requestCtx(deadline time.Time) (context.Context, context.CancelFunc) {
+	if deadline.IsZero() {
+		return context.Background(), func() {}
+	}
+	return context.WithDeadline(context.Background(), deadline)
 }
 
-func newHandlerCtx() fastrpc.HandlerCtx {
-	return &tlv.RequestCtx{
-		ConcurrencyLimitErrorHandler: concurrencyLimitErrorHandler,
+func handlerError(ctx *tlv.RequestCtx, version byte, err error) {
+	data := xrpc.MarshalError(err)
+	if version != ProtocolVersionJSON {
+		data = appendResponseEnvelope(make([]byte, 0, len(data)+2), responseFlagError, nil, data)
 	}
+	ctx.Response.SwapValue(data)
 }
 
-func concurrencyLimitErrorHandler(ctx *tlv.RequestCtx, concurrency int) {
-	ctx.Response.SwapValue([]byte(`{"error":"too many requests"}`))
+func newHandlerCtx(version byte) func() fastrpc.HandlerCtx {
+	return func() fastrpc.HandlerCtx {
+		return &tlv.RequestCtx{
+			ConcurrencyLimitErrorHandler: func(ctx *tlv.RequestCtx, concurrency int) {
+				handlerError(ctx, version, xrpc.ErrOverloaded)
+			},
+		}
+	}
 }
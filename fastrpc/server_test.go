@@ -0,0 +1,415 @@
+//
+// @project geniusrabbit::xrpc 2017
+// @author Dmitry Ponomarev <demdxx@gmail.com> 2017
+//
+
+package fastrpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/geniusrabbit/xrpc"
+	"github.com/valyala/fastrpc"
+	"github.com/valyala/fastrpc/tlv"
+)
+
+type benchMessage struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+func BenchmarkServerHandler(b *testing.B) {
+	var (
+		svc  = xrpc.New()
+		srv  = NewServer(svc).(*server)
+		resp = []byte(`"pong"`)
+	)
+	_ = svc.Register("ping", func(req xrpc.Request) error {
+		req.SetHeader("X-Pong", req.Header("X-Ping"))
+		_, err := req.Source().(*tlv.RequestCtx).Write(resp)
+		return err
+	})
+	_ = svc.Register("echo", func(req xrpc.Request) error {
+		var msg benchMessage
+		if err := req.Bind(&msg); err != nil {
+			return err
+		}
+		msg.Count++
+		return req.Send(&msg)
+	})
+
+	for _, bench := range []struct {
+		name string
+		data string
+	}{
+		{name: "ping", data: `"ping"`},
+		{name: "echo", data: `{"name":"bench","count":1}`},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			var (
+				ctx  tlv.RequestCtx
+				body = appendRequestEnvelope(nil, "1", time.Time{}, 0, "", map[string]string{"X-Ping": "1"}, []byte(bench.data))
+			)
+			ctx.Request.SetName(bench.name)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				ctx.Request.SetValue(body)
+				ctx.Response.Reset()
+				srv.handler(&ctx)
+			}
+			resp := &Response{version: ProtocolVersion, resp: &ctx.Response}
+			if err := resp.Error(); err != nil {
+				b.Fatal(err)
+			}
+		})
+	}
+}
+
+// newTestServer of the service listening the loopback address
+func newTestServer(t *testing.T, svc xrpc.Service, opts ...ServerOption) (xrpc.Server, string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	srv := NewServer(svc, opts...)
+	go func() { _ = srv.Serve(ln) }()
+	t.Cleanup(func() { _ = srv.Shutdown(context.Background()) })
+	return srv, ln.Addr().String()
+}
+
+func TestExpiredRequest(t *testing.T) {
+	var (
+		svc     = xrpc.New()
+		srv     = NewServer(svc).(*server)
+		called  bool
+		expired = time.Now().Add(-time.Second)
+	)
+	_ = svc.Register("ping", func(req xrpc.Request) error {
+		called = true
+		return req.Send("pong")
+	})
+
+	var ctx tlv.RequestCtx
+	ctx.Request.SetName("ping")
+	ctx.Request.SetValue(appendRequestEnvelope(nil, "1", expired, time.Millisecond, "", nil, []byte(`"ping"`)))
+	srv.handler(&ctx)
+	resp := &Response{version: ProtocolVersion, resp: &ctx.Response}
+	if err := resp.Error(); !errors.Is(err, xrpc.ErrDeadlineExceeded) || called {
+		t.Errorf("expired request must be rejected: %v", err)
+	}
+
+	var legacyCtx tlv.RequestCtx
+	legacyCtx.Request.SetName("ping")
+	_ = encodeJSONRequest(&legacyCtx.Request, xrpc.JSONCodec, xrpc.Message{Data: "ping"}, time.Millisecond, expired, []byte(`"ping"`))
+	srv.legacyHandler(&legacyCtx)
+	resp = &Response{version: ProtocolVersionJSON, resp: &legacyCtx.Response}
+	if err := resp.Error(); !errors.Is(err, xrpc.ErrDeadlineExceeded) || called {
+		t.Errorf("expired legacy request must be rejected: %v", err)
+	}
+}
+
+func TestDeadlineLimitedByTimeout(t *testing.T) {
+	var (
+		svc      = xrpc.New()
+		srv      = NewServer(svc).(*server)
+		deadline time.Time
+		skewed   = time.Now().Add(time.Hour)
+	)
+	_ = svc.Register("ping", func(req xrpc.Request) error {
+		deadline, _ = req.Context().Deadline()
+		return req.Send("pong")
+	})
+
+	// Deadline of the client with the clock ahead is limited by the timeout
+	var ctx tlv.RequestCtx
+	ctx.Request.SetName("ping")
+	ctx.Request.SetValue(appendRequestEnvelope(nil, "1", skewed, time.Minute, "", nil, []byte(`"ping"`)))
+	srv.handler(&ctx)
+	if limit := time.Now().Add(time.Minute); deadline.IsZero() || deadline.After(limit) {
+		t.Errorf("deadline must be limited by the timeout: %v", deadline)
+	}
+
+	deadline = time.Time{}
+	var legacyCtx tlv.RequestCtx
+	legacyCtx.Request.SetName("ping")
+	_ = encodeJSONRequest(&legacyCtx.Request, xrpc.JSONCodec, xrpc.Message{Data: "ping"}, time.Minute, skewed, []byte(`"ping"`))
+	srv.legacyHandler(&legacyCtx)
+	if limit := time.Now().Add(time.Minute); deadline.IsZero() || deadline.After(limit) {
+		t.Errorf("legacy deadline must be limited by the timeout: %v", deadline)
+	}
+}
+
+func TestDeadlineExceeded(t *testing.T) {
+	var (
+		svc    = xrpc.New()
+		ctxErr = make(chan error, 1)
+	)
+	_ = svc.Register("slow", func(req xrpc.Request) error {
+		select {
+		case <-req.Context().Done():
+		case <-time.After(time.Second):
+		}
+		ctxErr <- req.Context().Err()
+		return req.Context().Err()
+	})
+	_, addr := newTestServer(t, svc)
+
+	for _, version := range []byte{ProtocolVersion, ProtocolVersionJSON} {
+		client := NewClient(addr, WithProtocolVersion(version))
+		_, err := xrpc.Call[string, string](context.Background(), client, "slow", "", xrpc.WithTimeout(50*time.Millisecond))
+		if !errors.Is(err, xrpc.ErrDeadlineExceeded) {
+			t.Errorf("client of version %d must receive deadline exceeded error: %v", version, err)
+		}
+		if err = <-ctxErr; !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("handler context of version %d must be expired: %v", version, err)
+		}
+	}
+}
+
+func TestPanicRecovery(t *testing.T) {
+	var (
+		reported []interface{}
+		svc      = xrpc.New(xrpc.WithRecovery(false))
+		srv      = NewServer(svc, WithPanicReporter(func(req xrpc.Request, recovered interface{}, stack []byte) {
+			reported = append(reported, recovered)
+		})).(*server)
+	)
+	_ = svc.Register("panic", func(req xrpc.Request) error { panic("test panic") })
+
+	var ctx tlv.RequestCtx
+	ctx.Request.SetName("panic")
+	ctx.Request.SetValue(appendRequestEnvelope(nil, "1", time.Time{}, 0, "", nil, []byte(`""`)))
+	srv.handler(&ctx)
+	resp := &Response{version: ProtocolVersion, resp: &ctx.Response}
+	if err := resp.Error(); !errors.Is(err, xrpc.ErrInternal) {
+		t.Errorf("panic must be replied by internal error: %v", err)
+	}
+
+	var legacyCtx tlv.RequestCtx
+	legacyCtx.Request.SetName("panic")
+	_ = encodeJSONRequest(&legacyCtx.Request, xrpc.JSONCodec, xrpc.Message{}, 0, time.Time{}, []byte(`""`))
+	srv.legacyHandler(&legacyCtx)
+	resp = &Response{version: ProtocolVersionJSON, resp: &legacyCtx.Response}
+	if err := resp.Error(); !errors.Is(err, xrpc.ErrInternal) {
+		t.Errorf("panic of legacy request must be replied by internal error: %v", err)
+	}
+
+	if len(reported) != 2 || reported[0] != "test panic" {
+		t.Errorf("panics must be reported: %v", reported)
+	}
+}
+
+func TestHeadersCase(t *testing.T) {
+	svc := xrpc.New()
+	_ = svc.Register("echo", func(req xrpc.Request) error {
+		req.SetHeader("x-reply-ID", req.Header("x-request-user"))
+		return req.Send(string(req.Headers()["X-Request-User"]))
+	})
+	_, addr := newTestServer(t, svc)
+
+	for _, version := range []byte{ProtocolVersion, ProtocolVersionJSON} {
+		resp := NewClient(addr, WithProtocolVersion(version)).Send(xrpc.Message{
+			Action:  "echo",
+			Headers: map[string]interface{}{"X-REQUEST-user": "user1"},
+		})
+		var res string
+		if err := resp.Bind(&res); err != nil || res != "user1" {
+			t.Errorf("request header of version %d must be case insensitive: %s %v", version, res, err)
+		}
+		if value := resp.Header("X-Reply-Id"); string(value) != "user1" {
+			t.Errorf("response header of version %d must be case insensitive: %s", version, value)
+		}
+		if value := resp.Headers()["X-Reply-Id"]; string(value) != "user1" {
+			t.Errorf("response headers of version %d must be canonical: %v", version, resp.Headers())
+		}
+		resp.Release()
+	}
+}
+
+func TestProtocolVersions(t *testing.T) {
+	var (
+		svc     = xrpc.New()
+		started = make(chan struct{})
+		release = make(chan struct{})
+	)
+	_ = svc.Register("block", func(req xrpc.Request) error {
+		close(started)
+		<-release
+		return req.Send("done")
+	})
+	_ = svc.Register("ping", func(req xrpc.Request) error {
+		return req.Send("pong")
+	})
+	_, addr := newTestServer(t, svc, WithConcurrency(1))
+
+	var (
+		client       = NewClient(addr)
+		legacyClient = NewClient(addr, WithProtocolVersion(ProtocolVersionJSON))
+		done         = make(chan error, 1)
+	)
+	go func() {
+		_, err := xrpc.Call[string, string](context.Background(), client, "block", "", xrpc.WithTimeout(time.Second))
+		done <- err
+	}()
+	<-started
+
+	// The concurrency limit is shared by clients of both versions
+	_, err := xrpc.Call[string, string](context.Background(), legacyClient, "ping", "", xrpc.WithTimeout(time.Second))
+	if !errors.Is(err, xrpc.ErrOverloaded) {
+		t.Errorf("legacy client must be limited by the shared concurrency: %v", err)
+	}
+	close(release)
+	if err = <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []xrpc.Client{client, legacyClient, client} {
+		if res, err := xrpc.Call[string, string](context.Background(), c, "ping", "", xrpc.WithTimeout(time.Second)); err != nil || res != "pong" {
+			t.Errorf("invalid response: %s %v", res, err)
+		}
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	var (
+		svc     = xrpc.New()
+		started = make(chan struct{})
+		release = make(chan struct{})
+	)
+	_ = svc.Register("block", func(req xrpc.Request) error {
+		close(started)
+		<-release
+		return req.Send("done")
+	})
+	xsrv, addr := newTestServer(t, svc)
+	srv := xsrv.(*server)
+
+	inflight := make(chan error, 1)
+	go func() {
+		res, err := xrpc.Call[string, string](context.Background(), NewClient(addr), "block", "", xrpc.WithTimeout(time.Second))
+		if err == nil && res != "done" {
+			err = errors.New("invalid response: " + res)
+		}
+		inflight <- err
+	}()
+	<-started
+
+	shutdown := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		shutdown <- srv.Shutdown(ctx)
+	}()
+	for !srv.inflight.IsClosed() {
+		time.Sleep(time.Millisecond)
+	}
+
+	// New requests of existing connections are rejected during the shutdown
+	for _, version := range []byte{ProtocolVersion, ProtocolVersionJSON} {
+		var ctx tlv.RequestCtx
+		ctx.Request.SetName("block")
+		srv.handle(&ctx, version)
+		resp := &Response{version: version, resp: &ctx.Response}
+		if err := resp.Error(); !errors.Is(err, xrpc.ErrServerClosed) {
+			t.Errorf("new request of version %d must be rejected: %v", version, err)
+		}
+	}
+
+	close(release)
+	if err := <-inflight; err != nil {
+		t.Errorf("in-flight request must be finished: %v", err)
+	}
+	if err := <-shutdown; err != nil {
+		t.Errorf("shutdown must wait for in-flight request: %v", err)
+	}
+}
+
+func TestListen(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	addr := ln.Addr().String()
+	_ = ln.Close()
+
+	var (
+		svc    = xrpc.New()
+		srv    = NewServer(svc)
+		served = make(chan error, 1)
+	)
+	_ = svc.Register("ping", func(req xrpc.Request) error { return req.Send("pong") })
+	go func() { served <- srv.Listen("tcp://" + addr) }()
+
+	var res string
+	for i := 0; i < 100; i++ {
+		if res, err = xrpc.Call[string, string](context.Background(), NewClient(addr), "ping", ""); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil || res != "pong" {
+		t.Errorf("invalid response: %s %v", res, err)
+	}
+	if err = srv.Shutdown(context.Background()); err != nil {
+		t.Error(err)
+	}
+	if err = <-served; err != nil {
+		t.Errorf("listen must be stopped by shutdown: %v", err)
+	}
+	if err = srv.Listen("localhost"); err == nil {
+		t.Error("address without port must be invalid")
+	}
+}
+
+func TestLegacyServerResponse(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer ln.Close()
+
+	// Server of the previous releases replies by the action data as is
+	legacy := &fastrpc.Server{
+		SniffHeader:     "fastrpc",
+		ProtocolVersion: ProtocolVersionJSON,
+		NewHandlerCtx:   func() fastrpc.HandlerCtx { return &tlv.RequestCtx{} },
+		Handler: func(tctx fastrpc.HandlerCtx) fastrpc.HandlerCtx {
+			ctx := tctx.(*tlv.RequestCtx)
+			switch string(ctx.Request.Name()) {
+			case "hello":
+				_, _ = ctx.Write([]byte(`{"id":"1","msg":"Hello x!"}` + "\n"))
+			case "ping":
+				_, _ = ctx.Write([]byte(`"pong"` + "\n"))
+			default:
+				ctx.Response.SwapValue([]byte(`{"error":"not found"}`))
+			}
+			return ctx
+		},
+	}
+	go func() { _ = legacy.Serve(ln) }()
+
+	client := NewClient(ln.Addr().String(), WithProtocolVersion(ProtocolVersionJSON))
+
+	type hello struct {
+		ID  string `json:"id"`
+		Msg string `json:"msg"`
+	}
+	res, err := xrpc.Call[string, hello](context.Background(), client, "hello", "x", xrpc.WithTimeout(time.Second))
+	if err != nil || res.ID != "1" || res.Msg != "Hello x!" {
+		t.Errorf("invalid response of the legacy server: %+v %v", res, err)
+	}
+	pong, err := xrpc.Call[string, string](context.Background(), client, "ping", "", xrpc.WithTimeout(time.Second))
+	if err != nil || pong != "pong" {
+		t.Errorf("invalid response of the legacy server: %s %v", pong, err)
+	}
+	if _, err = xrpc.Call[string, string](context.Background(), client, "unknown", "", xrpc.WithTimeout(time.Second)); err == nil || err.Error() != "not found" {
+		t.Errorf("error of the legacy server must be returned: %v", err)
+	}
+}
@@ -0,0 +1,90 @@
+//
+// @project geniusrabbit::xrpc 2017
+// @author Dmitry Ponomarev <demdxx@gmail.com> 2017
+//
+
+package fastrpc
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/geniusrabbit/xrpc"
+	"github.com/geniusrabbit/xrpc/internal/testcert"
+)
+
+func TestServeMutualTLS(t *testing.T) {
+	var (
+		serverCert, serverKey = testcert.SelfSigned(t, "server")
+		clientCert, clientKey = testcert.SelfSigned(t, "client")
+		svc                   = xrpc.New()
+	)
+	_ = svc.Register("whoami", func(req xrpc.Request) error {
+		return req.Send(req.Peer().Subject())
+	})
+
+	serverConfig, err := xrpc.TLSOptions{CertFile: serverCert, KeyFile: serverKey, CAFile: clientCert}.ServerConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, addr := newTestServer(t, svc, WithTLS(serverConfig))
+
+	socket := filepath.Join(t.TempDir(), "service.sock")
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unixSrv := NewServer(svc, WithTLS(serverConfig))
+	go func() { _ = unixSrv.Serve(ln) }()
+	defer unixSrv.Shutdown(context.Background())
+
+	query := url.Values{"ca": {serverCert}, "cert": {clientCert}, "key": {clientKey}, "server_name": {"localhost"}}
+	for _, rawURL := range []string{
+		"fastrpc+tls+tcp://" + addr + "?" + query.Encode(),
+		"fastrpc+tls+tcp://" + addr + "?protocol=0&" + query.Encode(),
+		"fastrpc+tls+unix://" + socket + "?" + query.Encode(),
+		"fastrpc+tls+tcp://" + addr + "?clients=2&" + query.Encode(),
+	} {
+		client, err := xrpc.Dial(rawURL)
+		if err != nil {
+			t.Errorf("[%s] dial: %v", rawURL, err)
+			continue
+		}
+		// The peer certificate is extracted through the connection of the protocol listener
+		subject, err := xrpc.Call[string, string](context.Background(), client, "whoami", "", xrpc.WithTimeout(time.Second))
+		if err != nil || subject != "CN=client" {
+			t.Errorf("[%s] invalid peer of the client: %q %v", rawURL, subject, err)
+		}
+	}
+
+	client, err := xrpc.Dial("fastrpc+tls+tcp://" + addr + "?server_name=localhost&ca=" + url.QueryEscape(serverCert))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = xrpc.Call[string, string](context.Background(), client, "whoami", "", xrpc.WithTimeout(time.Second)); err == nil {
+		t.Error("client without certificate must be rejected")
+	}
+
+	if _, err = xrpc.Dial("fastrpc+tls+unix://" + socket + "?ca=" + url.QueryEscape(serverCert)); err == nil {
+		t.Error("server_name option must be required for the unix socket")
+	}
+	if err = NewServer(svc).Listen("tls+tcp://127.0.0.1:0"); err == nil {
+		t.Error("listen TLS address without configuration must fail")
+	}
+}
+
+func TestMultipleClientTLSConfig(t *testing.T) {
+	var (
+		inBand = &tls.Config{ServerName: "in-band"}
+		client = NewMultipleClient(1, "localhost:20202").(*MultipleClient)
+	)
+	client.TLSConfig = inBand
+	if cli := client.client(); cli.TLSConfig != inBand {
+		t.Error("deprecated TLSConfig must be used for the TLS after the protocol handshake")
+	}
+}
@@ -0,0 +1,113 @@
+//
+// @project geniusrabbit::xrpc 2017
+// @author Dmitry Ponomarev <demdxx@gmail.com> 2017
+//
+
+package fastrpc
+
+import (
+	"fmt"
+
+	"github.com/geniusrabbit/xrpc"
+)
+
+func init() {
+	xrpc.RegisterTransport("fastrpc", transport{})
+}
+
+// transport of the `fastrpc` URL scheme
+type transport struct{}
+
+// Dial client of the address, supported options:
+//
+//	codec     name of the registered codec
+//	compress  compression of requests: none, flate, snappy
+//	protocol  version of the protocol, 0 for servers without binary envelope
+//	clients   number of connections, MultipleClient is used if more than one
+//
+// TLS options of the `fastrpc+tls+tcp://` address:
+//
+//	ca           file of certificates to verify the server
+//	cert, key    files of the client certificate for mutual TLS
+//	server_name  verified name of the server, host by default, required for unix sockets
+//	insecure     skip verification of the server
+func (transport) Dial(address xrpc.Address) (xrpc.Client, error) {
+	codec, err := address.Codec()
+	if err != nil {
+		return nil, err
+	}
+	compress, err := compressTypeOf(address)
+	if err != nil {
+		return nil, err
+	}
+	protocol, err := address.Int("protocol", int(ProtocolVersion))
+	if err != nil {
+		return nil, err
+	}
+	if protocol != int(ProtocolVersion) && protocol != int(ProtocolVersionJSON) {
+		return nil, fmt.Errorf("unsupported protocol version [%d]", protocol)
+	}
+	clients, err := address.Int("clients", 1)
+	if err != nil {
+		return nil, err
+	}
+	if clients > 1 {
+		client := NewMultipleClient(clients, address.String()).(*MultipleClient)
+		client.Codec = codec
+		client.CompressType = compress
+		client.ProtocolVersion = byte(protocol)
+		return client, client.err
+	}
+
+	client := NewClient(address.String(),
+		WithRequestCompress(compress),
+		WithProtocolVersion(byte(protocol)),
+	).(*Client)
+	client.Codec = codec
+	return client, client.err
+}
+
+// NewServer of the service, supported options:
+//
+//	codec        name of the codec of requests without codec name
+//	compress     compression of responses: none, flate, snappy
+//	concurrency  maximum number of concurrently processed requests
+//	cert, key    files of the server certificate, required for TLS
+//	ca           file of certificates to verify clients (mutual TLS)
+func (transport) NewServer(svc xrpc.Service, address xrpc.Address) (xrpc.Server, error) {
+	codec, err := address.Codec()
+	if err != nil {
+		return nil, err
+	}
+	compress, err := compressTypeOf(address)
+	if err != nil {
+		return nil, err
+	}
+	concurrency, err := address.Int("concurrency", 100)
+	if err != nil {
+		return nil, err
+	}
+	opts := []ServerOption{WithCodec(codec), WithResponseCompress(compress), WithConcurrency(concurrency)}
+	if address.TLS {
+		config, err := address.TLSOptions().ServerConfig()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithTLS(config))
+	}
+	return NewServer(svc, opts...), nil
+}
+
+// compressTypeOf the `compress` option of the address
+func compressTypeOf(address xrpc.Address) (CompressType, error) {
+	switch name := address.Query.Get("compress"); name {
+	case "", "none":
+		return CompressNone, nil
+	case "flate":
+		return CompressFlate, nil
+	case "snappy":
+		return CompressSnappy, nil
+	default:
+		return CompressNone, fmt.Errorf("unsupported compress type [%s]", name)
+	}
+}
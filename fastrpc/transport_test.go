@@ -0,0 +1,54 @@
+//
+// @project geniusrabbit::xrpc 2017
+// @author Dmitry Ponomarev <demdxx@gmail.com> 2017
+//
+
+package fastrpc
+
+import (
+	"testing"
+
+	"github.com/geniusrabbit/xrpc"
+	"github.com/valyala/fastrpc"
+)
+
+func TestTransportDial(t *testing.T) {
+	client, err := xrpc.Dial("fastrpc://localhost:20202?clients=2&compress=snappy&codec=json&protocol=0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	multiple, ok := client.(*MultipleClient)
+	if !ok {
+		t.Fatalf("multiple client expected: %T", client)
+	}
+	if len(multiple.clients) != 2 || len(multiple.Addrs) != 1 || multiple.Addrs[0].Addr != "localhost:20202" {
+		t.Errorf("invalid clients: %d %+v", len(multiple.clients), multiple.Addrs)
+	}
+	if multiple.CompressType != CompressSnappy || multiple.Codec != xrpc.JSONCodec || multiple.ProtocolVersion != ProtocolVersionJSON {
+		t.Errorf("invalid client options: %v %v %d", multiple.CompressType, multiple.Codec, multiple.ProtocolVersion)
+	}
+
+	if client, err = xrpc.Dial("fastrpc://localhost:20202?compress=flate"); err != nil {
+		t.Fatal(err)
+	}
+	single, ok := client.(*Client)
+	if !ok {
+		t.Fatalf("single client expected: %T", client)
+	}
+	if single.client.Addr != "localhost:20202" || single.client.CompressType != fastrpc.CompressFlate ||
+		single.client.ProtocolVersion != ProtocolVersion || single.Codec != nil {
+		t.Errorf("invalid client options: %+v", single.client)
+	}
+
+	for _, url := range []string{
+		"fastrpc://localhost",
+		"fastrpc://localhost:20202?compress=gzip",
+		"fastrpc://localhost:20202?clients=two",
+		"fastrpc://localhost:20202?protocol=2",
+		"fastrpc://localhost:20202?protocol=256",
+	} {
+		if _, err := xrpc.Dial(url); err == nil {
+			t.Errorf("[%s] error expected", url)
+		}
+	}
+}
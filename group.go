@@ -0,0 +1,78 @@
+//
+// @project geniusrabbit::xrpc 2017
+// @author Dmitry Ponomarev <demdxx@gmail.com> 2017
+//
+
+package xrpc
+
+// group of actions registered with the name prefix
+type group struct {
+	svc    *service
+	prefix string
+}
+
+// Wrap all actions of the group with the middleware function
+func (g *group) Wrap(m MiddlewareFunc) {
+	g.svc.WrapGroup(g.prefix, m)
+}
+
+// Register action function with the group prefix
+func (g *group) Register(name string, fnk Action, middlewaries ...MiddlewareFunc) error {
+	return g.svc.Register(g.prefix+name, fnk, middlewaries...)
+}
+
+// RegisterReceiver methods with the group prefix
+func (g *group) RegisterReceiver(prefix string, receiver interface{}, naming ...NamingStrategy) error {
+	return registerReceiver(g.svc, g.prefix+prefix, receiver, firstNaming(naming))
+}
+
+// Replace action function with the group prefix
+func (g *group) Replace(name string, fnk Action) error {
+	return g.svc.Replace(g.prefix+name, fnk)
+}
+
+// Unregister action with the group prefix
+func (g *group) Unregister(name string) error {
+	return g.svc.Unregister(g.prefix + name)
+}
+
+// Describe action with the group prefix
+func (g *group) Describe(name string, info ActionInfo) error {
+	return g.svc.Describe(g.prefix+name, info)
+}
+
+// Group of actions with the nested prefix
+func (g *group) Group(prefix string) Registrar {
+	return &group{svc: g.svc, prefix: g.prefix + prefix}
+}
+
+// Mount service with the nested prefix
+func (g *group) Mount(prefix string, svc Service) error {
+	return g.svc.Mount(g.prefix+prefix, svc)
+}
+
+// mountedService processes all actions with the prefix
+type mountedService struct {
+	prefix []byte
+	svc    Service
+}
+
+func (m *mountedService) handle(req Request) error {
+	return m.svc.Handle(&prefixedRequest{Request: req, action: req.Action()[len(m.prefix):]})
+}
+
+// prefixedRequest replaces action name of the request without the mount prefix
+type prefixedRequest struct {
+	Request
+	action []byte
+}
+
+// Action name without the mount prefix
+func (r *prefixedRequest) Action() []byte {
+	return r.action
+}
+
+// Unwrap original request
+func (r *prefixedRequest) Unwrap() Request {
+	return r.Request
+}
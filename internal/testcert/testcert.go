@@ -0,0 +1,115 @@
+//
+// @project geniusrabbit::xrpc 2017
+// @author Dmitry Ponomarev <demdxx@gmail.com> 2017
+//
+
+// Package testcert issues certificates for TLS tests of transports
+package testcert
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// CA issues certificates into the test directory
+type CA struct {
+	// File of the CA certificate, empty for self-signed certificates
+	File string
+
+	dir  string
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// NewCA writes the CA certificate into the test directory
+func NewCA(t testing.TB) *CA {
+	t.Helper()
+	ca := &CA{dir: t.TempDir()}
+	ca.cert, ca.key = ca.issue(t, "ca", &x509.Certificate{
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	})
+	ca.File, _ = ca.write(t, "ca", ca.cert, nil)
+	return ca
+}
+
+// SelfSigned writes the certificate valid for the server and client
+// authentication, the certificate file is used as CA as well
+func SelfSigned(t testing.TB, name string) (certFile, keyFile string) {
+	t.Helper()
+	ca := &CA{dir: t.TempDir()}
+	return ca.Pair(t, name, x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth)
+}
+
+// issue the certificate signed by the CA, self-signed if the CA is not defined
+func (ca *CA) issue(t testing.TB, name string, tmpl *x509.Certificate) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	tmpl.SerialNumber = serial
+	tmpl.Subject = pkix.Name{CommonName: name}
+	tmpl.NotBefore = time.Now().Add(-time.Hour)
+	tmpl.NotAfter = time.Now().Add(time.Hour)
+
+	parent, signer := tmpl, key
+	if ca.cert != nil {
+		parent, signer = ca.cert, ca.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+// Pair issues the certificate for localhost and writes it with the key into files
+func (ca *CA) Pair(t testing.TB, name string, usage ...x509.ExtKeyUsage) (certFile, keyFile string) {
+	t.Helper()
+	cert, key := ca.issue(t, name, &x509.Certificate{
+		DNSNames:    []string{"localhost"},
+		IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1)},
+		ExtKeyUsage: usage,
+	})
+	return ca.write(t, name, cert, key)
+}
+
+// write the certificate and the key if it's defined into files of the test directory
+func (ca *CA) write(t testing.TB, name string, cert *x509.Certificate, key *ecdsa.PrivateKey) (certFile, keyFile string) {
+	t.Helper()
+	certFile = filepath.Join(ca.dir, name+".crt")
+	writePEM(t, certFile, "CERTIFICATE", cert.Raw)
+	if key != nil {
+		der, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keyFile = filepath.Join(ca.dir, name+".key")
+		writePEM(t, keyFile, "EC PRIVATE KEY", der)
+	}
+	return certFile, keyFile
+}
+
+func writePEM(t testing.TB, filename, typ string, der []byte) {
+	t.Helper()
+	if err := os.WriteFile(filename, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -16,4 +16,7 @@ type Message struct {
 	Timeout time.Duration
 	Headers map[string]interface{}
 	Data    interface{}
+
+	// Codec of data encoding, the client codec is used if not defined
+	Codec Codec
 }
@@ -0,0 +1,108 @@
+//
+// @project geniusrabbit::xrpc 2017
+// @author Dmitry Ponomarev <demdxx@gmail.com> 2017
+//
+
+// Package openrpc generates OpenRPC documents of xrpc services
+// from the typed information of registered actions.
+package openrpc
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/geniusrabbit/xrpc"
+)
+
+// Version of OpenRPC specification
+const Version = "1.2.6"
+
+// Info about the service API
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// ContentDescriptor of method params and result
+type ContentDescriptor struct {
+	Name     string  `json:"name"`
+	Required bool    `json:"required,omitempty"`
+	Schema   *Schema `json:"schema"`
+}
+
+// Method of the service
+type Method struct {
+	Name        string               `json:"name"`
+	Summary     string               `json:"summary,omitempty"`
+	Description string               `json:"description,omitempty"`
+	Deprecated  bool                 `json:"deprecated,omitempty"`
+	Params      []*ContentDescriptor `json:"params"`
+	Result      *ContentDescriptor   `json:"result"`
+}
+
+// Components of the document
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas,omitempty"`
+}
+
+// Document of OpenRPC specification
+type Document struct {
+	OpenRPC    string      `json:"openrpc"`
+	Info       Info        `json:"info"`
+	Methods    []*Method   `json:"methods"`
+	Components *Components `json:"components,omitempty"`
+}
+
+// New document of the service actions.
+// Actions without input type have no params and results
+// without output type are described as any value.
+func New(svc xrpc.Service, info Info) *Document {
+	var (
+		gen = newSchemaGenerator("#/components/schemas/")
+		doc = &Document{OpenRPC: Version, Info: info, Methods: []*Method{}}
+	)
+
+	for _, action := range svc.Actions() {
+		method := &Method{
+			Name:       action.Name,
+			Summary:    action.Description,
+			Deprecated: action.IsDeprecated(),
+			Params:     []*ContentDescriptor{},
+			Result:     &ContentDescriptor{Name: "result", Schema: &Schema{}},
+		}
+		if action.IsDeprecated() {
+			method.Description = "Deprecated: " + action.Deprecated
+		}
+		if action.InputType != nil {
+			method.Params = append(method.Params, &ContentDescriptor{
+				Name:     "params",
+				Required: true,
+				Schema:   gen.schema(action.InputType),
+			})
+		}
+		if action.OutputType != nil {
+			method.Result.Schema = gen.schema(action.OutputType)
+		}
+		doc.Methods = append(doc.Methods, method)
+	}
+
+	if len(gen.definitions) > 0 {
+		doc.Components = &Components{Schemas: gen.definitions}
+	}
+	return doc
+}
+
+// JSON encoded document
+func (doc *Document) JSON() ([]byte, error) {
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+// WriteFile with the OpenRPC document of the service
+func WriteFile(svc xrpc.Service, info Info, filename string) error {
+	data, err := New(svc, info).JSON()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, data, 0644)
+}
@@ -0,0 +1,89 @@
+//
+// @project geniusrabbit::xrpc 2017
+// @author Dmitry Ponomarev <demdxx@gmail.com> 2017
+//
+
+package openrpc
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/geniusrabbit/xrpc"
+)
+
+type testBase struct {
+	ID uint64 `json:"id"`
+}
+
+type testInput struct {
+	testBase
+	Name    string         `json:"name"`
+	Tags    []string       `json:"tags,omitempty"`
+	Extra   map[string]int `json:"extra,omitempty"`
+	Created time.Time      `json:"created"`
+	Parent  *testInput     `json:"parent,omitempty"`
+	Skip    string         `json:"-"`
+	Data    []byte         `json:"data,omitempty"`
+	Any     interface{}    `json:"any,omitempty"`
+	private string
+}
+
+type testOutput struct {
+	Msg string `json:"msg"`
+}
+
+func TestDocument(t *testing.T) {
+	svc := xrpc.New()
+	xrpc.Handle(svc, "hello", func(ctx context.Context, in *testInput) (*testOutput, error) {
+		return &testOutput{}, nil
+	})
+	svc.Describe("hello", xrpc.ActionInfo{Description: "Say hello", Deprecated: "use greet"})
+	svc.Register("raw", func(req xrpc.Request) error { return nil })
+
+	data, err := New(svc, Info{Title: "test", Version: "1.0"}).JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var doc Document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Methods) != 2 || doc.Methods[0].Name != "hello" || doc.Methods[1].Name != "raw" {
+		t.Fatalf("invalid methods: %s", data)
+	}
+
+	hello := doc.Methods[0]
+	if !hello.Deprecated || hello.Summary != "Say hello" || len(hello.Params) != 1 {
+		t.Errorf("invalid method: %s", data)
+	}
+	if hello.Params[0].Schema.Ref != "#/components/schemas/testInput" ||
+		hello.Result.Schema.Ref != "#/components/schemas/testOutput" {
+		t.Errorf("invalid method schema refs: %s", data)
+	}
+
+	input := doc.Components.Schemas["testInput"]
+	if input == nil {
+		t.Fatalf("input schema is not defined: %s", data)
+	}
+	for name, tp := range map[string]string{"id": "integer", "name": "string", "tags": "array",
+		"extra": "object", "created": "string", "data": "string"} {
+		if prop := input.Properties[name]; prop == nil || prop.Type != tp {
+			t.Errorf("invalid property [%s]: %v", name, prop)
+		}
+	}
+	if input.Properties["parent"] == nil || input.Properties["parent"].Ref != "#/components/schemas/testInput" {
+		t.Errorf("invalid recursive property: %v", input.Properties["parent"])
+	}
+	for _, name := range []string{"Skip", "private", "testBase"} {
+		if input.Properties[name] != nil {
+			t.Errorf("property [%s] must be skipped", name)
+		}
+	}
+	if len(input.Required) != 3 {
+		t.Errorf("invalid required list: %v", input.Required)
+	}
+}
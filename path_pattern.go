@@ -0,0 +1,213 @@
+//
+// @project geniusrabbit::xrpc 2017
+// @author Dmitry Ponomarev <demdxx@gmail.com> 2017
+//
+
+package xrpc
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"sync"
+)
+
+var (
+	errInvalidPattern = errors.New("Invalid path pattern")
+)
+
+// WildcardParam name of the value captured by trailing wildcard
+const WildcardParam = "*"
+
+// Param value captured by action path pattern
+type Param struct {
+	Name  string
+	Value []byte
+}
+
+// Params list of the request
+type Params []Param
+
+// Get param value by name
+func (p Params) Get(name string) []byte {
+	for _, param := range p {
+		if param.Name == name {
+			return param.Value
+		}
+	}
+	return nil
+}
+
+// ParamsOf returns params captured from the action name of the request
+func ParamsOf(req Request) Params {
+	for req != nil {
+		switch r := req.(type) {
+		case interface{ Params() Params }:
+			return r.Params()
+		case interface{ Unwrap() Request }:
+			req = r.Unwrap()
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// ParamsSetter is implemented by requests which keep params captured
+// by the path pattern, transports reuse params buffer of pooled requests
+type ParamsSetter interface {
+	Params() Params
+	SetParams(params Params)
+}
+
+// paramsRequest contains params captured by path pattern
+// for requests which don't keep params
+type paramsRequest struct {
+	Request
+	params Params
+}
+
+var paramsRequestPool = sync.Pool{
+	New: func() interface{} { return &paramsRequest{} },
+}
+
+func releaseParamsRequest(r *paramsRequest) {
+	for i := range r.params {
+		r.params[i] = Param{}
+	}
+	r.Request, r.params = nil, r.params[:0]
+	paramsRequestPool.Put(r)
+}
+
+// Params captured from the action name
+func (r *paramsRequest) Params() Params {
+	return r.params
+}
+
+// Unwrap original request
+func (r *paramsRequest) Unwrap() Request {
+	return r.Request
+}
+
+type segmentType uint8
+
+const (
+	segmentStatic segmentType = iota
+	segmentParam
+	segmentWildcard
+)
+
+type patternSegment struct {
+	tp    segmentType
+	value string
+}
+
+// pathPattern of the action name like `user/{id}/profile` or `proxy/*`.
+// Trailing wildcard could be glued to the static prefix `geo*`.
+type pathPattern struct {
+	pattern  string
+	segments []patternSegment
+	params   int
+	action   Action
+}
+
+func isPathPattern(path []byte) bool {
+	return bytes.IndexByte(path, '{') >= 0 || bytes.IndexByte(path, '*') >= 0
+}
+
+func newPathPattern(path string, action Action) (*pathPattern, error) {
+	var (
+		parts   = strings.Split(path, "/")
+		pattern = &pathPattern{pattern: path, action: action}
+	)
+	for i, part := range parts {
+		switch {
+		case strings.HasSuffix(part, "*"):
+			if i != len(parts)-1 || strings.Count(part, "*") > 1 || strings.ContainsAny(part, "{}") {
+				return nil, errInvalidPattern
+			}
+			pattern.segments = append(pattern.segments, patternSegment{tp: segmentWildcard, value: part[:len(part)-1]})
+			pattern.params++
+		case strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}"):
+			name := part[1 : len(part)-1]
+			if name == "" || strings.ContainsAny(name, "{}*") {
+				return nil, errInvalidPattern
+			}
+			pattern.segments = append(pattern.segments, patternSegment{tp: segmentParam, value: name})
+			pattern.params++
+		case strings.ContainsAny(part, "{}*"):
+			return nil, errInvalidPattern
+		default:
+			pattern.segments = append(pattern.segments, patternSegment{tp: segmentStatic, value: part})
+		}
+	}
+	return pattern, nil
+}
+
+// less returns true if the pattern has priority over another one.
+// Patterns with named segments are matched before wildcards
+// and longer static parts are matched first.
+func (p *pathPattern) less(p2 *pathPattern) bool {
+	if w1, w2 := p.hasWildcard(), p2.hasWildcard(); w1 != w2 {
+		return w2
+	}
+	return p.staticLen() > p2.staticLen()
+}
+
+func (p *pathPattern) hasWildcard() bool {
+	return p.segments[len(p.segments)-1].tp == segmentWildcard
+}
+
+func (p *pathPattern) staticLen() (size int) {
+	for _, seg := range p.segments {
+		if seg.tp != segmentParam {
+			size += len(seg.value) + 1
+		}
+	}
+	return size
+}
+
+// match path with the pattern and append captured params to the buffer
+func (p *pathPattern) match(path []byte, params Params) (Params, bool) {
+	var offset int
+	if cap(params) < p.params {
+		params = make(Params, 0, p.params)
+	}
+	for i, seg := range p.segments {
+		if offset > len(path) {
+			return params, false
+		}
+		var (
+			rest = path[offset:]
+			part = rest
+			last = true
+		)
+		if idx := bytes.IndexByte(rest, '/'); idx >= 0 {
+			part, last = rest[:idx], false
+		}
+
+		switch seg.tp {
+		case segmentWildcard:
+			if len(rest) < len(seg.value) || string(rest[:len(seg.value)]) != seg.value {
+				return params, false
+			}
+			return append(params, Param{Name: WildcardParam, Value: rest[len(seg.value):]}), true
+		case segmentParam:
+			if len(part) < 1 {
+				return params, false
+			}
+			params = append(params, Param{Name: seg.value, Value: part})
+		default:
+			if string(part) != seg.value {
+				return params, false
+			}
+		}
+
+		// All segments of the path must be matched
+		if last != (i == len(p.segments)-1) {
+			return params, false
+		}
+		offset += len(part) + 1
+	}
+	return params, true
+}
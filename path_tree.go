@@ -19,10 +19,61 @@ type peaceType [3]byte
 type pathTree interface {
 	Add(path []byte, action Action) error
 	Node(path []byte) *pathTreeNode
+
+	// Match action by exact path or by the path pattern,
+	// captured params are appended to the truncated params buffer
+	Match(path []byte, params Params) (Action, Params)
 }
 
 func newTree() pathTree {
-	return &pathTreeNode{}
+	return &pathTreeRoot{}
+}
+
+// pathTreeRoot contains exact paths tree and patterns
+// which are checked if there is no exact match
+type pathTreeRoot struct {
+	pathTreeNode
+	patterns []*pathPattern
+}
+
+func (t *pathTreeRoot) Add(path []byte, action Action) error {
+	if !isPathPattern(path) {
+		return t.pathTreeNode.Add(path, action)
+	}
+
+	pattern, err := newPathPattern(string(path), action)
+	if err != nil {
+		return err
+	}
+
+	for i, p := range t.patterns {
+		if p.pattern == pattern.pattern {
+			t.patterns[i] = pattern
+			return nil
+		}
+	}
+
+	i := sort.Search(len(t.patterns), func(i int) bool {
+		return pattern.less(t.patterns[i])
+	})
+	t.patterns = append(t.patterns, nil)
+	copy(t.patterns[i+1:], t.patterns[i:])
+	t.patterns[i] = pattern
+	return nil
+}
+
+func (t *pathTreeRoot) Match(path []byte, params Params) (Action, Params) {
+	params = params[:0]
+	if node := t.Node(path); node != nil && node.Action != nil {
+		return node.Action, params
+	}
+	for _, pattern := range t.patterns {
+		var ok bool
+		if params, ok = pattern.match(path, params[:0]); ok {
+			return pattern.action, params
+		}
+	}
+	return nil, params[:0]
 }
 
 type pathTreeNode struct {
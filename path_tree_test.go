@@ -86,3 +86,78 @@ func randomBytes(length int) (b []byte) {
 	}
 	return
 }
+
+func TestTreePatterns(t *testing.T) {
+	var (
+		matched  string
+		testTree = newTree()
+		action   = func(name string) Action {
+			return func(req Request) error {
+				matched = name
+				return nil
+			}
+		}
+	)
+
+	for _, pattern := range []string{"user/{id}/profile", "user/me/profile", "proxy/*", "proxy/static/*", "geo*", "user/{id}"} {
+		if err := testTree.Add([]byte(pattern), action(pattern)); err != nil {
+			t.Fatalf("add pattern [%s]: %v", pattern, err)
+		}
+	}
+
+	tests := []struct {
+		path    string
+		pattern string
+		params  Params
+	}{
+		{path: "user/me/profile", pattern: "user/me/profile"},
+		{path: "user/100/profile", pattern: "user/{id}/profile", params: Params{{Name: "id", Value: []byte("100")}}},
+		{path: "user/100", pattern: "user/{id}", params: Params{{Name: "id", Value: []byte("100")}}},
+		{path: "proxy/a/b", pattern: "proxy/*", params: Params{{Name: "*", Value: []byte("a/b")}}},
+		{path: "proxy/static/a", pattern: "proxy/static/*", params: Params{{Name: "*", Value: []byte("a")}}},
+		{path: "geo/lookup", pattern: "geo*", params: Params{{Name: "*", Value: []byte("/lookup")}}},
+		{path: "user/100/settings"},
+		{path: "user//profile"},
+		{path: "prox"},
+	}
+
+	for _, test := range tests {
+		matched = ""
+		act, params := testTree.Match([]byte(test.path), nil)
+		if act == nil {
+			if test.pattern != "" {
+				t.Errorf("path [%s] must match [%s]", test.path, test.pattern)
+			}
+			continue
+		}
+		_ = act(nil)
+		if matched != test.pattern {
+			t.Errorf("path [%s] must match [%s], got [%s]", test.path, test.pattern, matched)
+		}
+		if len(params) != len(test.params) {
+			t.Errorf("path [%s] invalid params: %v", test.path, params)
+			continue
+		}
+		for _, param := range test.params {
+			if string(params.Get(param.Name)) != string(param.Value) {
+				t.Errorf("path [%s] invalid param [%s]: %s", test.path, param.Name, params.Get(param.Name))
+			}
+		}
+	}
+
+	for _, pattern := range []string{"a/*/b", "a/{}", "a/{id", "a/**"} {
+		if err := testTree.Add([]byte(pattern), nil); err == nil {
+			t.Errorf("pattern [%s] must be invalid", pattern)
+		}
+	}
+}
+
+func BenchmarkTreeMatch(b *testing.B) {
+	initData()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			_, _ = testTree.Match(keys2[i%len(keys2)], nil)
+		}
+	})
+}
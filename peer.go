@@ -0,0 +1,78 @@
+//
+// @project geniusrabbit::xrpc 2017
+// @author Dmitry Ponomarev <demdxx@gmail.com> 2017
+//
+
+package xrpc
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+)
+
+// Peer describes the connection of the request
+type Peer struct {
+	// Transport name like `http` or `fastrpc`
+	Transport string
+
+	// RemoteAddr of the caller
+	RemoteAddr net.Addr
+
+	// LocalAddr of the server connection
+	LocalAddr net.Addr
+
+	// TLS is true for the secure connection
+	TLS bool
+
+	// Certificate of the client verified by the server,
+	// nil if the client has not presented the certificate
+	Certificate *x509.Certificate
+}
+
+// Subject of the verified client certificate, empty for anonymous clients
+func (p Peer) Subject() string {
+	if p.Certificate == nil {
+		return ""
+	}
+	return p.Certificate.Subject.String()
+}
+
+// PeerOf the connection, TLS information is extracted from connections
+// which provide the connection state like *tls.Conn or wrap such connection
+func PeerOf(transport string, conn net.Conn) Peer {
+	peer := Peer{Transport: transport}
+	if conn == nil {
+		return peer
+	}
+	peer.RemoteAddr = conn.RemoteAddr()
+	peer.LocalAddr = conn.LocalAddr()
+	if tlsConn := tlsConnOf(conn); tlsConn != nil {
+		state := tlsConn.ConnectionState()
+		peer.TLS = true
+		if len(state.VerifiedChains) > 0 && len(state.VerifiedChains[0]) > 0 {
+			peer.Certificate = state.VerifiedChains[0][0]
+		}
+	}
+	return peer
+}
+
+type tlsConnectionStater interface {
+	ConnectionState() tls.ConnectionState
+}
+
+// tlsConnOf returns the TLS connection which could be wrapped
+// by the connections providing `NetConn() net.Conn` method
+func tlsConnOf(conn net.Conn) tlsConnectionStater {
+	for conn != nil {
+		if tlsConn, ok := conn.(tlsConnectionStater); ok {
+			return tlsConn
+		}
+		wrapper, ok := conn.(interface{ NetConn() net.Conn })
+		if !ok {
+			return nil
+		}
+		conn = wrapper.NetConn()
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+//
+// @project geniusrabbit::xrpc 2017
+// @author Dmitry Ponomarev <demdxx@gmail.com> 2017
+//
+
+package xrpc
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"testing"
+)
+
+type testTLSConn struct {
+	net.Conn
+	state tls.ConnectionState
+}
+
+func (c testTLSConn) ConnectionState() tls.ConnectionState { return c.state }
+
+func TestPeerOf(t *testing.T) {
+	conn, other := net.Pipe()
+	defer conn.Close()
+	defer other.Close()
+
+	peer := PeerOf("test", conn)
+	if peer.Transport != "test" || peer.RemoteAddr == nil || peer.TLS || peer.Subject() != "" {
+		t.Errorf("invalid plain peer: %+v", peer)
+	}
+
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "client"}}
+	peer = PeerOf("test", testTLSConn{Conn: conn, state: tls.ConnectionState{
+		VerifiedChains: [][]*x509.Certificate{{cert}},
+	}})
+	if !peer.TLS || peer.Subject() != "CN=client" {
+		t.Errorf("invalid TLS peer: %+v", peer)
+	}
+
+	peer = PeerOf("test", nil)
+	if peer.Transport != "test" || peer.RemoteAddr != nil {
+		t.Errorf("invalid empty peer: %+v", peer)
+	}
+}
@@ -0,0 +1,124 @@
+//
+// @project geniusrabbit::xrpc 2017
+// @author Dmitry Ponomarev <demdxx@gmail.com> 2017
+//
+
+package xrpc
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+var (
+	errNilReceiver       = errors.New("Receiver is nil")
+	errNoReceiverMethods = errors.New("Receiver has no methods with supported signatures")
+)
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	requestType = reflect.TypeOf((*Request)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// NamingStrategy converts method name into the action name
+type NamingStrategy func(name string) string
+
+// registerReceiver registers all exported methods of the receiver with supported signatures:
+//
+//	func(ctx context.Context, in In) (Out, error)
+//	func(req xrpc.Request) error
+//
+// Methods with other signatures are skipped. All actions are published
+// by the single snapshot, so the receiver is registered entirely or not at all.
+func registerReceiver(svc *service, prefix string, receiver interface{}, naming NamingStrategy) error {
+	val := reflect.ValueOf(receiver)
+	if !val.IsValid() || (val.Kind() == reflect.Ptr && val.IsNil()) {
+		return errNilReceiver
+	}
+	if naming == nil {
+		naming = SnakeCase
+	}
+
+	var (
+		tp   = val.Type()
+		acts []*registeredAction
+	)
+	for i := 0; i < tp.NumMethod(); i++ {
+		action, info := receiverAction(val.Method(i))
+		if action == nil {
+			continue
+		}
+		acts = append(acts, &registeredAction{name: prefix + naming(tp.Method(i).Name), action: action, info: info})
+	}
+	if len(acts) == 0 {
+		return errNoReceiverMethods
+	}
+	return svc.registerActions(acts)
+}
+
+// receiverAction returns action of the method or nil if signature is not supported
+func receiverAction(method reflect.Value) (Action, ActionInfo) {
+	mt := method.Type()
+	switch {
+	case mt.NumIn() == 1 && mt.NumOut() == 1 && mt.In(0) == requestType && mt.Out(0) == errorType:
+		return method.Interface().(func(Request) error), ActionInfo{}
+	case mt.NumIn() == 2 && mt.NumOut() == 2 && mt.In(0) == contextType && mt.Out(1) == errorType:
+		inType := mt.In(1)
+		info := ActionInfo{InputType: inType, OutputType: mt.Out(0)}
+		return func(req Request) error {
+			in := reflect.New(inType)
+			if err := req.Bind(in.Interface()); err != nil {
+				return NewError(CodeInvalidArgument, err.Error())
+			}
+			out := method.Call([]reflect.Value{reflect.ValueOf(req.Context()), in.Elem()})
+			if err, _ := out[1].Interface().(error); err != nil {
+				return err
+			}
+			return req.Send(out[0].Interface())
+		}, info
+	}
+	return nil, ActionInfo{}
+}
+
+func firstNaming(naming []NamingStrategy) NamingStrategy {
+	if len(naming) > 0 {
+		return naming[0]
+	}
+	return nil
+}
+
+// SnakeCase naming strategy: GetUserByID -> get_user_by_id
+func SnakeCase(name string) string {
+	var (
+		buf   strings.Builder
+		runes = []rune(name)
+	)
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (!unicode.IsUpper(runes[i-1]) ||
+				(i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+				buf.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		buf.WriteRune(r)
+	}
+	return buf.String()
+}
+
+// LowerCamelCase naming strategy: GetUserByID -> getUserByID, IDList -> idList
+func LowerCamelCase(name string) string {
+	runes := []rune(name)
+	for i := 0; i < len(runes) && unicode.IsUpper(runes[i]); i++ {
+		// Keep the last upper letter of the abbreviation before the next word
+		if i > 0 && i+1 < len(runes) && unicode.IsLower(runes[i+1]) {
+			break
+		}
+		runes[i] = unicode.ToLower(runes[i])
+	}
+	return string(runes)
+}
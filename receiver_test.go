@@ -0,0 +1,124 @@
+//
+// @project geniusrabbit::xrpc 2017
+// @author Dmitry Ponomarev <demdxx@gmail.com> 2017
+//
+
+package xrpc
+
+import (
+	"context"
+	"testing"
+)
+
+type testReceiver struct{}
+
+func (testReceiver) SayHello(ctx context.Context, in *testInput) (*testOutput, error) {
+	return &testOutput{Msg: "Hello " + in.Name}, nil
+}
+
+func (testReceiver) RawRequest(req Request) error {
+	return req.Send(&testOutput{Msg: "raw"})
+}
+
+func (testReceiver) Unsupported(a, b int) int {
+	return a + b
+}
+
+func TestRegisterReceiver(t *testing.T) {
+	svc := New()
+	if err := svc.RegisterReceiver("geo/", testReceiver{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Group("v2/").RegisterReceiver("", &testReceiver{}, LowerCamelCase); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		action string
+		resp   string
+	}{
+		{action: "geo/say_hello", resp: `{"msg":"Hello test"}`},
+		{action: "geo/raw_request", resp: `{"msg":"raw"}`},
+		{action: "v2/sayHello", resp: `{"msg":"Hello test"}`},
+	}
+	for _, test := range tests {
+		req := &testRequest{action: test.action, data: []byte(`{"name":"test"}`), ctx: context.Background()}
+		if err := svc.Handle(req); err != nil {
+			t.Errorf("action [%s]: %v", test.action, err)
+		} else if string(req.resp) != test.resp {
+			t.Errorf("action [%s] invalid response: %s", test.action, req.resp)
+		}
+	}
+
+	if err := svc.Handle(&testRequest{action: "geo/unsupported", ctx: context.Background()}); err != ErrActionNotFound {
+		t.Errorf("expected error %v, got %v", ErrActionNotFound, err)
+	}
+}
+
+func TestRegisterReceiverErrors(t *testing.T) {
+	var (
+		svc         = New()
+		nilReceiver *testReceiver
+	)
+	for _, receiver := range []interface{}{nil, nilReceiver} {
+		if err := svc.RegisterReceiver("", receiver); err != errNilReceiver {
+			t.Errorf("nil receiver %T must be rejected: %v", receiver, err)
+		}
+	}
+	if err := svc.RegisterReceiver("", 1); err != errNoReceiverMethods {
+		t.Errorf("receiver without methods must be rejected: %v", err)
+	}
+
+	// Invalid name of any method rejects the whole receiver
+	// and keeps the action registered before with the same name
+	_ = svc.Register("raw_request", func(req Request) error { return req.Send("previous") })
+	naming := func(name string) string {
+		if name == "SayHello" {
+			return "{hello"
+		}
+		return SnakeCase(name)
+	}
+	if err := svc.RegisterReceiver("", testReceiver{}, naming); err != errInvalidPattern {
+		t.Errorf("invalid action name must be rejected: %v", err)
+	}
+	if actions := svc.Actions(); len(actions) != 1 || actions[0].Name != "raw_request" {
+		t.Errorf("actions must not be registered partially: %v", actions)
+	}
+	req := &testRequest{action: "raw_request", ctx: context.Background()}
+	if err := svc.Handle(req); err != nil || string(req.resp) != `"previous"` {
+		t.Errorf("previous action must be kept: %s %v", req.resp, err)
+	}
+
+	// Actions of the receiver replace registered actions with the same names
+	if err := svc.RegisterReceiver("", testReceiver{}); err != nil {
+		t.Fatal(err)
+	}
+	req = &testRequest{action: "raw_request", ctx: context.Background()}
+	if err := svc.Handle(req); err != nil || string(req.resp) != `{"msg":"raw"}` {
+		t.Errorf("action must be replaced by the receiver: %s %v", req.resp, err)
+	}
+	if actions := svc.Actions(); len(actions) != 2 || actions[1].Name != "say_hello" || actions[1].InputType == nil {
+		t.Errorf("actions of the receiver must be registered with the info: %v", actions)
+	}
+}
+
+func TestNamingStrategy(t *testing.T) {
+	tests := []struct {
+		name  string
+		snake string
+		camel string
+	}{
+		{name: "GetUserByID", snake: "get_user_by_id", camel: "getUserByID"},
+		{name: "IDList", snake: "id_list", camel: "idList"},
+		{name: "Predict", snake: "predict", camel: "predict"},
+		{name: "HTTPServer2", snake: "http_server2", camel: "httpServer2"},
+	}
+	for _, test := range tests {
+		if res := SnakeCase(test.name); res != test.snake {
+			t.Errorf("SnakeCase(%s) = %s, expected %s", test.name, res, test.snake)
+		}
+		if res := LowerCamelCase(test.name); res != test.camel {
+			t.Errorf("LowerCamelCase(%s) = %s, expected %s", test.name, res, test.camel)
+		}
+	}
+}
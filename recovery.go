@@ -0,0 +1,47 @@
+//
+// @project geniusrabbit::xrpc 2017
+// @author Dmitry Ponomarev <demdxx@gmail.com> 2017
+//
+
+package xrpc
+
+import (
+	"log"
+	"runtime/debug"
+)
+
+// PanicReporter receives panics recovered in actions with the stack trace
+type PanicReporter func(req Request, recovered interface{}, stack []byte)
+
+// WithRecovery turns on or off recovering of panics in actions by the service,
+// recovery is enabled by default. Disabled recovery passes panics to the caller
+// of Handle, servers of transports still recover them to keep the connections
+// and report them by the reporter of the server instead of the service one.
+func WithRecovery(enabled bool) Option {
+	return func(svc *service) {
+		svc.recovery = enabled
+	}
+}
+
+// WithPanicReporter defines reporter of recovered panics,
+// by default panics are written to the standard logger
+func WithPanicReporter(reporter PanicReporter) Option {
+	return func(svc *service) {
+		svc.panicReporter = reporter
+	}
+}
+
+// LogPanicReporter writes panic information to the standard logger
+func LogPanicReporter(req Request, recovered interface{}, stack []byte) {
+	log.Printf("xrpc: panic in action [%s]: %v\n%s", req.Action(), recovered, stack)
+}
+
+// recoverPanic converts panic into the internal error, must be called by defer
+func (s *service) recoverPanic(req Request, err *error) {
+	if rec := recover(); rec != nil {
+		if s.panicReporter != nil {
+			s.panicReporter(req, rec, debug.Stack())
+		}
+		*err = ErrInternal
+	}
+}
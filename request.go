@@ -21,6 +21,19 @@ type Request interface {
 	// Timeout value
 	Timeout() time.Duration
 
+	// Header value of the request by name
+	Header(name string) []byte
+
+	// Headers of the request
+	Headers() map[string][]byte
+
+	// SetHeader of the response, it could be called before or after Send
+	// until the action is finished
+	SetHeader(name string, value []byte)
+
+	// Peer information of the request connection
+	Peer() Peer
+
 	// External Context
 	Context() context.Context
 
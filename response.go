@@ -13,6 +13,16 @@ type Response interface {
 	// Bind message to object or structure
 	Bind(target interface{}) error
 
+	// Header value of the response by name
+	Header(name string) []byte
+
+	// Headers of the response set by the action
+	Headers() map[string][]byte
+
 	// Error response
 	Error() error
+
+	// Release the response into the pool of the transport,
+	// the response and its headers must not be used after that
+	Release()
 }
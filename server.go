@@ -5,9 +5,135 @@
 
 package xrpc
 
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrServerClosed is returned by Listen after the server shutdown
+// and replied to requests received during the shutdown
+var ErrServerClosed = errors.New("Server closed")
+
 // Server implements paticular transport level of service
 type Server interface {
 	// Listen some address which could be any connection type like:
 	// tcp://hostname:port or udp://... or unix://... etc.
 	Listen(address string) error
+
+	// Serve connections of the listener, the listener is closed by Shutdown
+	Serve(listener net.Listener) error
+
+	// Shutdown stops accepting new connections, rejects new requests
+	// of existing connections with ErrServerClosed and waits for in-flight
+	// requests until the context is done. If some requests are not finished
+	// in time the *ShutdownError with the number of them is returned.
+	Shutdown(ctx context.Context) error
+}
+
+// ShutdownError reports requests interrupted by the server shutdown
+type ShutdownError struct {
+	Interrupted int
+	Err         error
+}
+
+func (e *ShutdownError) Error() string {
+	return fmt.Sprintf("shutdown: %d requests interrupted: %v", e.Interrupted, e.Err)
+}
+
+// Unwrap returns the context error
+func (e *ShutdownError) Unwrap() error {
+	return e.Err
+}
+
+// listenAllShutdownTimeout limits the shutdown of the server by the listen error
+var listenAllShutdownTimeout = 5 * time.Second
+
+// ListenAll addresses by the server, all listeners are stopped by the server
+// shutdown. The first fatal error shutdowns the server and is returned after
+// all listeners are stopped, in-flight requests are waited for a limited time.
+func ListenAll(srv Server, addrs ...string) error {
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	wg.Add(len(addrs))
+	for _, addr := range addrs {
+		go func(addr string) {
+			defer wg.Done()
+			if err := srv.Listen(addr); err != nil {
+				once.Do(func() {
+					firstErr = err
+					ctx, cancel := context.WithTimeout(context.Background(), listenAllShutdownTimeout)
+					defer cancel()
+					_ = srv.Shutdown(ctx)
+				})
+			}
+		}(addr)
+	}
+	wg.Wait()
+	return firstErr
+}
+
+// shutdownPollInterval of checking for in-flight requests
+const shutdownPollInterval = 10 * time.Millisecond
+
+// Inflight counts requests in processing for the graceful shutdown of servers
+type Inflight struct {
+	count  int64
+	closed int32
+}
+
+// Begin processing of the request, returns false after Close
+func (f *Inflight) Begin() bool {
+	atomic.AddInt64(&f.count, 1)
+	if atomic.LoadInt32(&f.closed) != 0 {
+		f.Done()
+		return false
+	}
+	return true
+}
+
+// Done processing of the request
+func (f *Inflight) Done() {
+	atomic.AddInt64(&f.count, -1)
+}
+
+// Count of requests in processing
+func (f *Inflight) Count() int {
+	return int(atomic.LoadInt64(&f.count))
+}
+
+// Close prevents processing of new requests
+func (f *Inflight) Close() {
+	atomic.StoreInt32(&f.closed, 1)
+}
+
+// IsClosed returns true after Close
+func (f *Inflight) IsClosed() bool {
+	return atomic.LoadInt32(&f.closed) != 0
+}
+
+// Wait for in-flight requests until the context is done
+func (f *Inflight) Wait(ctx context.Context) error {
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+	for {
+		if f.Count() <= 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			if count := f.Count(); count > 0 {
+				return &ShutdownError{Interrupted: count, Err: ctx.Err()}
+			}
+			return nil
+		case <-ticker.C:
+		}
+	}
 }
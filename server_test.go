@@ -0,0 +1,112 @@
+//
+// @project geniusrabbit::xrpc 2017
+// @author Dmitry Ponomarev <demdxx@gmail.com> 2017
+//
+
+package xrpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testServer struct {
+	mx     sync.Mutex
+	listen map[string]bool
+	stop   chan struct{}
+	once   sync.Once
+
+	// inflight requests block the shutdown until the context is done
+	inflight bool
+}
+
+func (s *testServer) Listen(address string) error {
+	s.mx.Lock()
+	s.listen[address] = true
+	s.mx.Unlock()
+	if address == "invalid" {
+		return errors.New("invalid address")
+	}
+	<-s.stop
+	return nil
+}
+
+func (s *testServer) Serve(listener net.Listener) error { return nil }
+
+func (s *testServer) Shutdown(ctx context.Context) error {
+	s.once.Do(func() { close(s.stop) })
+	if s.inflight {
+		<-ctx.Done()
+		return &ShutdownError{Interrupted: 1, Err: ctx.Err()}
+	}
+	return nil
+}
+
+func (s *testServer) listened() int {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return len(s.listen)
+}
+
+func TestInflight(t *testing.T) {
+	var inflight Inflight
+
+	if !inflight.Begin() || !inflight.Begin() {
+		t.Fatal("requests must be accepted before close")
+	}
+	inflight.Done()
+	inflight.Close()
+
+	if inflight.Begin() {
+		t.Error("requests must be rejected after close")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	var shutdownErr *ShutdownError
+	if err := inflight.Wait(ctx); !errors.As(err, &shutdownErr) || shutdownErr.Interrupted != 1 {
+		t.Errorf("one interrupted request expected: %v", err)
+	}
+	if !errors.Is(shutdownErr, context.DeadlineExceeded) {
+		t.Errorf("context error expected: %v", shutdownErr.Err)
+	}
+
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		inflight.Done()
+	}()
+	if err := inflight.Wait(context.Background()); err != nil || inflight.Count() != 0 {
+		t.Errorf("all requests must be finished: %v", err)
+	}
+}
+
+func TestListenAll(t *testing.T) {
+	srv := &testServer{listen: map[string]bool{}, stop: make(chan struct{})}
+	if err := ListenAll(srv, "tcp://:1", "tcp://:2", "invalid"); err == nil || err.Error() != "invalid address" {
+		t.Errorf("the listen error expected: %v", err)
+	}
+	if n := srv.listened(); n != 3 {
+		t.Errorf("all addresses must be listened: %d", n)
+	}
+
+	// Shutdown by the listen error is limited in time
+	defer func(timeout time.Duration) { listenAllShutdownTimeout = timeout }(listenAllShutdownTimeout)
+	listenAllShutdownTimeout = 10 * time.Millisecond
+
+	srv = &testServer{listen: map[string]bool{}, stop: make(chan struct{}), inflight: true}
+	done := make(chan error, 1)
+	go func() { done <- ListenAll(srv, "tcp://:1", "invalid") }()
+	select {
+	case err := <-done:
+		if err == nil || err.Error() != "invalid address" {
+			t.Errorf("the listen error expected: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("listen error must be returned after the limited shutdown")
+	}
+}
@@ -7,64 +7,409 @@ package xrpc
 
 import (
 	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
 )
 
 // Service errors
 var (
-	ErrActionNotFound  = errors.New("Action not found")
-	ErrInvalidResponse = errors.New("Invalid response")
+	ErrActionNotFound   = errors.New("Action not found")
+	ErrInvalidResponse  = errors.New("Invalid response")
+	ErrDeadlineExceeded = errors.New("Deadline exceeded")
+	ErrUnavailable      = errors.New("Service unavailable")
+	ErrOverloaded       = errors.New("Too many requests")
+	ErrInternal         = errors.New("Internal server error")
 )
 
-// Middleware of service
+// Middleware of service which is executed before the action
 type Middleware interface {
 	Handle(req Request) error
 }
 
+// MiddlewareFunc wraps the action processing and has access
+// to the request, result error and duration of the next action
+//
+// Example:
+//
+//	svc.Wrap(func(next xrpc.Action) xrpc.Action {
+//	  return func(req xrpc.Request) error {
+//	    start := time.Now()
+//	    err := next(req)
+//	    log.Println(string(req.Action()), time.Since(start), err)
+//	    return err
+//	  }
+//	})
+type MiddlewareFunc func(next Action) Action
+
+// Registrar of actions
+type Registrar interface {
+	// Wrap action processing with the middleware function.
+	// Middlewares are applied in the order of registration,
+	// the first one is the outermost.
+	Wrap(m MiddlewareFunc)
+
+	// Register service action function with action specific middlewares.
+	// The name could be a pattern with named segments `user/{id}/profile`
+	// or trailing wildcard `proxy/*`, captured values are accessible by ParamsOf.
+	// Exact names have priority over patterns.
+	Register(name string, fnk Action, middlewaries ...MiddlewareFunc) error
+
+	// Replace action function of the registered action, the action
+	// middlewares and metadata are kept. It's safe to call under live traffic.
+	Replace(name string, fnk Action) error
+
+	// Unregister action by name, mounted services are unregistered
+	// by the name `prefix*`. It's safe to call under live traffic.
+	Unregister(name string) error
+
+	// RegisterReceiver registers all exported methods of the receiver
+	// with signatures `func(ctx context.Context, in In) (Out, error)`
+	// or `func(req xrpc.Request) error` under the name prefix.
+	// Action names are converted by the naming strategy, SnakeCase by default.
+	RegisterReceiver(prefix string, receiver interface{}, naming ...NamingStrategy) error
+
+	// Describe registered action by metadata, not empty fields
+	// of the info replace current values
+	Describe(name string, info ActionInfo) error
+
+	// Group of actions with the name prefix
+	Group(prefix string) Registrar
+
+	// Mount delegates processing of all actions with the name prefix
+	// to another service, the prefix is cut from the action name,
+	// so it can't contain pattern segments
+	Mount(prefix string, svc Service) error
+}
+
 // Service accessor interface
 type Service interface {
+	Registrar
+
 	// Use middleware in the loop
 	Use(m Middleware)
 
-	// Register service action function
-	Register(name string, fnk Action) error
+	// WrapGroup wraps processing of all actions with the name prefix
+	// by the middleware function. Group middlewares are applied after
+	// the service middlewares and before the action middlewares.
+	WrapGroup(prefix string, m MiddlewareFunc)
+
+	// Actions returns the list of registered actions sorted by name,
+	// actions of mounted services are included with the mount prefix
+	Actions() []ActionInfo
 
 	// Handle paticular request
 	Handle(req Request) error
 }
 
+type registeredAction struct {
+	name         string
+	action       Action
+	middlewaries []MiddlewareFunc
+	info         ActionInfo
+	mount        Service
+
+	// handler is the action wrapped by all middlewaries
+	handler Action
+}
+
+type groupMiddleware struct {
+	prefix string
+	m      MiddlewareFunc
+}
+
+// Option of the service
+type Option func(svc *service)
+
+// service keeps immutable snapshot of the actions tree which is replaced
+// on every modification, so registration is safe under live traffic
 type service struct {
-	actions      pathTree
-	middlewaries []Middleware
+	mx                sync.Mutex
+	actions           atomic.Value
+	registry          []*registeredAction
+	middlewaries      []MiddlewareFunc
+	groupMiddlewaries []groupMiddleware
+
+	recovery      bool
+	panicReporter PanicReporter
 }
 
 // New sevice default connector
-func New() Service {
-	return &service{
-		actions: newTree(),
+func New(opts ...Option) Service {
+	svc := &service{recovery: true, panicReporter: LogPanicReporter}
+	for _, opt := range opts {
+		opt(svc)
 	}
+	svc.actions.Store(newTree())
+	return svc
 }
 
 // Use middleware in the loop
 func (s *service) Use(m Middleware) {
 	if m != nil {
-		s.middlewaries = append(s.middlewaries, m)
+		s.Wrap(MiddlewareOf(m))
+	}
+}
+
+// Wrap action processing with the middleware function
+func (s *service) Wrap(m MiddlewareFunc) {
+	if m == nil {
+		return
 	}
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.middlewaries = append(s.middlewaries, m)
+	s.rewrap()
 }
 
-// Register service action function
-func (s *service) Register(name string, fnk Action) error {
-	return s.actions.Add([]byte(name), fnk)
+// WrapGroup wraps processing of all actions with the name prefix
+func (s *service) WrapGroup(prefix string, m MiddlewareFunc) {
+	if m == nil {
+		return
+	}
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.groupMiddlewaries = append(s.groupMiddlewaries, groupMiddleware{prefix: prefix, m: m})
+	s.rewrap()
+}
+
+// Register service action function with action specific middlewares
+func (s *service) Register(name string, fnk Action, middlewaries ...MiddlewareFunc) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	_, err := s.update(&registeredAction{name: name, action: fnk, middlewaries: middlewaries}, false)
+	return err
+}
+
+// Replace action function of the registered action, action middlewares are kept
+func (s *service) Replace(name string, fnk Action) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	found, err := s.update(&registeredAction{name: name, action: fnk}, true)
+	if err == nil && !found {
+		err = ErrActionNotFound
+	}
+	return err
+}
+
+// Unregister action by name
+func (s *service) Unregister(name string) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	registry := make([]*registeredAction, 0, len(s.registry))
+	for _, act := range s.registry {
+		if act.name != name {
+			registry = append(registry, act)
+		}
+	}
+	if len(registry) == len(s.registry) {
+		return ErrActionNotFound
+	}
+	return s.publish(registry)
+}
+
+// RegisterReceiver registers all exported methods of the receiver
+func (s *service) RegisterReceiver(prefix string, receiver interface{}, naming ...NamingStrategy) error {
+	return registerReceiver(s, prefix, receiver, firstNaming(naming))
+}
+
+// Group of actions with the name prefix
+func (s *service) Group(prefix string) Registrar {
+	return &group{svc: s, prefix: prefix}
+}
+
+// Mount delegates processing of all actions with the name prefix to another service
+func (s *service) Mount(prefix string, svc Service) error {
+	if prefix == "" {
+		return errPathIsEmpty
+	}
+	// The prefix is cut from the action name as is
+	if strings.ContainsAny(prefix, "{}*") {
+		return errInvalidPattern
+	}
+	mount := &mountedService{prefix: []byte(prefix), svc: svc}
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	_, err := s.update(&registeredAction{name: prefix + WildcardParam, action: mount.handle, mount: svc}, false)
+	return err
+}
+
+// Describe registered action by metadata
+func (s *service) Describe(name string, info ActionInfo) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	for _, act := range s.registry {
+		if act.name == name {
+			act.info.merge(info)
+			return nil
+		}
+	}
+	return ErrActionNotFound
+}
+
+// Actions returns the list of registered actions
+func (s *service) Actions() []ActionInfo {
+	s.mx.Lock()
+	registry := s.registry
+	infos := make([]ActionInfo, 0, len(registry))
+	for _, act := range registry {
+		if act.mount == nil {
+			info := act.info
+			info.Name = act.name
+			infos = append(infos, info)
+		}
+	}
+	s.mx.Unlock()
+
+	// Mounted services are requested without lock to prevent deadlocks
+	for _, act := range registry {
+		if act.mount != nil {
+			prefix := act.name[:len(act.name)-len(WildcardParam)]
+			for _, info := range act.mount.Actions() {
+				info.Name = prefix + info.Name
+				infos = append(infos, info)
+			}
+		}
+	}
+	sortActions(infos)
+	return infos
 }
 
 // Handle action
-func (s *service) Handle(req Request) error {
-	if node := s.actions.Node(req.Action()); node != nil {
-		for _, m := range s.middlewaries {
+func (s *service) Handle(req Request) (err error) {
+	if s.recovery {
+		defer s.recoverPanic(req, &err)
+	}
+	tree := s.actions.Load().(pathTree)
+
+	// Params are kept by the request itself or by the pooled wrapper
+	if preq, ok := req.(ParamsSetter); ok {
+		action, params := tree.Match(req.Action(), preq.Params())
+		if preq.SetParams(params); action == nil {
+			return ErrActionNotFound
+		}
+		return action(req)
+	}
+
+	preq := paramsRequestPool.Get().(*paramsRequest)
+	action, params := tree.Match(req.Action(), preq.params)
+	if preq.params = params; action == nil || len(params) == 0 {
+		releaseParamsRequest(preq)
+		if action == nil {
+			return ErrActionNotFound
+		}
+		return action(req)
+	}
+	preq.Request = req
+	defer releaseParamsRequest(preq)
+	return action(preq)
+}
+
+// update registry by the action, if replace is true the action must be registered
+// before and its middlewares are kept
+func (s *service) update(act *registeredAction, replace bool) (found bool, err error) {
+	registry := make([]*registeredAction, 0, len(s.registry)+1)
+	for _, reg := range s.registry {
+		if reg.name == act.name {
+			if replace {
+				act.middlewaries = reg.middlewaries
+				act.info = reg.info
+			}
+			reg, found = act, true
+		}
+		registry = append(registry, reg)
+	}
+	if !found {
+		if replace {
+			return false, nil
+		}
+		registry = append(registry, act)
+	}
+	act.handler = s.wrap(act)
+	return found, s.publish(registry)
+}
+
+// registerActions of the batch by the single snapshot, so the batch is visible
+// entirely or not at all and replaced actions are kept if the registration fails
+func (s *service) registerActions(acts []*registeredAction) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	batch := make(map[string]*registeredAction, len(acts))
+	for _, act := range acts {
+		act.handler = s.wrap(act)
+		batch[act.name] = act
+	}
+	registry := make([]*registeredAction, 0, len(s.registry)+len(acts))
+	for _, reg := range s.registry {
+		if act, ok := batch[reg.name]; ok {
+			delete(batch, reg.name)
+			reg = act
+		}
+		registry = append(registry, reg)
+	}
+	for _, act := range acts {
+		if act, ok := batch[act.name]; ok {
+			delete(batch, act.name)
+			registry = append(registry, act)
+		}
+	}
+	return s.publish(registry)
+}
+
+// rewrap registered actions with the new chain of middlewares
+func (s *service) rewrap() {
+	registry := make([]*registeredAction, 0, len(s.registry))
+	for _, act := range s.registry {
+		act = &registeredAction{name: act.name, action: act.action, middlewaries: act.middlewaries, info: act.info, mount: act.mount}
+		act.handler = s.wrap(act)
+		registry = append(registry, act)
+	}
+	_ = s.publish(registry)
+}
+
+// publish new snapshot of the actions tree
+func (s *service) publish(registry []*registeredAction) error {
+	tree := newTree()
+	for _, act := range registry {
+		if err := tree.Add([]byte(act.name), act.handler); err != nil {
+			return err
+		}
+	}
+	s.registry = registry
+	s.actions.Store(tree)
+	return nil
+}
+
+// wrap action by the chain of middlewares
+func (s *service) wrap(act *registeredAction) Action {
+	fnk := act.action
+	for i := len(act.middlewaries) - 1; i >= 0; i-- {
+		if act.middlewaries[i] != nil {
+			fnk = act.middlewaries[i](fnk)
+		}
+	}
+	for i := len(s.groupMiddlewaries) - 1; i >= 0; i-- {
+		if group := s.groupMiddlewaries[i]; strings.HasPrefix(act.name, group.prefix) {
+			fnk = group.m(fnk)
+		}
+	}
+	for i := len(s.middlewaries) - 1; i >= 0; i-- {
+		fnk = s.middlewaries[i](fnk)
+	}
+	return fnk
+}
+
+// MiddlewareOf converts the middleware into the wrapping function
+func MiddlewareOf(m Middleware) MiddlewareFunc {
+	return func(next Action) Action {
+		return func(req Request) error {
 			if err := m.Handle(req); err != nil {
 				return err
 			}
+			return next(req)
 		}
-		return node.Action(req)
 	}
-	return ErrActionNotFound
 }
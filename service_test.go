@@ -0,0 +1,319 @@
+//
+// @project geniusrabbit::xrpc 2017
+// @author Dmitry Ponomarev <demdxx@gmail.com> 2017
+//
+
+package xrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type testMiddleware struct {
+	calls *[]string
+}
+
+func (m testMiddleware) Handle(req Request) error {
+	*m.calls = append(*m.calls, "handle")
+	return nil
+}
+
+func TestServiceMiddleware(t *testing.T) {
+	var (
+		calls  []string
+		svc    = New()
+		errAct = errors.New("action error")
+	)
+
+	svc.Register("test", func(req Request) error {
+		calls = append(calls, "action")
+		return errAct
+	})
+
+	svc.Wrap(func(next Action) Action {
+		return func(req Request) error {
+			calls = append(calls, "before")
+			err := next(req)
+			if err == errAct {
+				calls = append(calls, "after")
+				return nil
+			}
+			return err
+		}
+	})
+	svc.Use(testMiddleware{calls: &calls})
+
+	if err := svc.Handle(&testRequest{action: "test", ctx: context.Background()}); err != nil {
+		t.Errorf("error must be processed by middleware: %v", err)
+	}
+	if res := strings.Join(calls, ","); res != "before,handle,action,after" {
+		t.Errorf("invalid middleware order: %s", res)
+	}
+}
+
+func TestServiceActionMiddleware(t *testing.T) {
+	var (
+		calls []string
+		svc   = New()
+		mark  = func(name string) MiddlewareFunc {
+			return func(next Action) Action {
+				return func(req Request) error {
+					calls = append(calls, name)
+					return next(req)
+				}
+			}
+		}
+		action = func(req Request) error {
+			calls = append(calls, "action")
+			return nil
+		}
+	)
+
+	svc.Register("admin/users", action, mark("users"))
+	svc.Register("public", action)
+	svc.WrapGroup("admin/", mark("admin"))
+	svc.Wrap(mark("global"))
+
+	for _, test := range []struct {
+		action string
+		calls  string
+	}{
+		{action: "admin/users", calls: "global,admin,users,action"},
+		{action: "public", calls: "global,action"},
+	} {
+		calls = calls[:0]
+		if err := svc.Handle(&testRequest{action: test.action, ctx: context.Background()}); err != nil {
+			t.Error(err)
+		}
+		if res := strings.Join(calls, ","); res != test.calls {
+			t.Errorf("invalid middlewares of [%s]: %s", test.action, res)
+		}
+	}
+}
+
+func TestServiceGroupMount(t *testing.T) {
+	var (
+		calls []string
+		svc   = New()
+		geo   = New()
+		mark  = func(name string) Action {
+			return func(req Request) error {
+				calls = append(calls, name+":"+string(req.Action()))
+				return nil
+			}
+		}
+	)
+
+	billing := svc.Group("billing/")
+	billing.Register("charge", mark("charge"))
+	billing.Group("admin/").Register("refund", mark("refund"))
+	geo.Register("lookup", mark("geo"))
+	svc.Mount("geo/", geo)
+	svc.Register("geo/special", mark("special"))
+
+	for _, action := range []string{"billing/charge", "billing/admin/refund", "geo/lookup", "geo/special"} {
+		if err := svc.Handle(&testRequest{action: action, ctx: context.Background()}); err != nil {
+			t.Errorf("action [%s]: %v", action, err)
+		}
+	}
+	if res := strings.Join(calls, ","); res != "charge:billing/charge,refund:billing/admin/refund,geo:lookup,special:geo/special" {
+		t.Errorf("invalid calls: %s", res)
+	}
+	if err := svc.Handle(&testRequest{action: "geo/unknown", ctx: context.Background()}); err != ErrActionNotFound {
+		t.Errorf("expected error %v, got %v", ErrActionNotFound, err)
+	}
+}
+
+func TestServiceParams(t *testing.T) {
+	var (
+		id  string
+		svc = New()
+		geo = New()
+	)
+
+	geo.Register("user/{id}", func(req Request) error {
+		id = string(ParamsOf(req).Get("id"))
+		return nil
+	})
+	svc.Mount("geo/", geo)
+
+	if err := svc.Handle(&testRequest{action: "geo/user/10", ctx: context.Background()}); err != nil {
+		t.Fatal(err)
+	}
+	if id != "10" {
+		t.Errorf("invalid param value: %s", id)
+	}
+
+	// Params are kept by the request which implements ParamsSetter
+	req := &paramsTestRequest{testRequest: testRequest{ctx: context.Background()}, action: []byte("geo/user/20")}
+	if err := svc.Handle(req); err != nil {
+		t.Fatal(err)
+	}
+	if id != "20" || string(req.params.Get(WildcardParam)) != "user/20" {
+		t.Errorf("invalid params of the request: %s %v", id, req.params)
+	}
+
+	if err := svc.Mount("user/{id}/", geo); err != errInvalidPattern {
+		t.Errorf("mount prefix with pattern segments must be rejected: %v", err)
+	}
+}
+
+// paramsTestRequest with the static action name which keeps params
+type paramsTestRequest struct {
+	testRequest
+	action []byte
+	params Params
+}
+
+func (r *paramsTestRequest) Action() []byte          { return r.action }
+func (r *paramsTestRequest) Params() Params          { return r.params }
+func (r *paramsTestRequest) SetParams(params Params) { r.params = params }
+
+// staticRequest returns the action name without allocations
+type staticRequest struct {
+	testRequest
+	action []byte
+}
+
+func (r *staticRequest) Action() []byte { return r.action }
+
+func BenchmarkServicePattern(b *testing.B) {
+	var (
+		svc    = New()
+		req    = &staticRequest{testRequest: testRequest{ctx: context.Background()}, action: []byte("user/100/profile")}
+		preq   = &paramsTestRequest{testRequest: testRequest{ctx: context.Background()}, action: req.action}
+		action = func(req Request) error {
+			if len(ParamsOf(req).Get("id")) == 0 {
+				return ErrInvalidResponse
+			}
+			return nil
+		}
+	)
+	_ = svc.Register("user/{id}/profile", action)
+
+	b.Run("wrapped", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if err := svc.Handle(req); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("params", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if err := svc.Handle(preq); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestServiceConcurrentRegistration(t *testing.T) {
+	var (
+		wg   sync.WaitGroup
+		svc  = New()
+		stop = make(chan struct{})
+		ok   = func(req Request) error { return nil }
+	)
+
+	svc.Register("test", ok)
+
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if err := svc.Handle(&testRequest{action: "test", ctx: context.Background()}); err != nil {
+					t.Error(err)
+					return
+				}
+				_ = svc.Handle(&testRequest{action: "plugin", ctx: context.Background()})
+			}
+		}()
+	}
+
+	for i := 0; i < 100; i++ {
+		svc.Register("plugin", ok)
+		svc.Replace("test", func(req Request) error { return nil })
+		svc.Unregister("plugin")
+	}
+	close(stop)
+	wg.Wait()
+
+	if err := svc.Unregister("plugin"); err != ErrActionNotFound {
+		t.Errorf("expected error %v, got %v", ErrActionNotFound, err)
+	}
+	if err := svc.Replace("plugin", ok); err != ErrActionNotFound {
+		t.Errorf("expected error %v, got %v", ErrActionNotFound, err)
+	}
+}
+
+func TestServiceActions(t *testing.T) {
+	var (
+		svc = New()
+		geo = New()
+	)
+
+	Handle(svc, "hello", func(ctx context.Context, in *testInput) (*testOutput, error) {
+		return &testOutput{}, nil
+	})
+	svc.Describe("hello", ActionInfo{Description: "Say hello", Deprecated: "use greet"})
+	geo.Register("lookup", func(req Request) error { return nil })
+	svc.Mount("geo/", geo)
+	RegisterDescribe(svc)
+
+	req := &testRequest{action: DescribeAction, ctx: context.Background()}
+	if err := svc.Handle(req); err != nil {
+		t.Fatal(err)
+	}
+
+	var actions []ActionInfo
+	if err := json.Unmarshal(req.resp, &actions); err != nil {
+		t.Fatal(err)
+	}
+	if len(actions) != 3 || actions[0].Name != DescribeAction || actions[1].Name != "geo/lookup" || actions[2].Name != "hello" {
+		t.Fatalf("invalid actions list: %s", req.resp)
+	}
+	if hello := actions[2]; hello.Input != "*xrpc.testInput" || hello.Output != "*xrpc.testOutput" ||
+		hello.Description != "Say hello" || hello.Deprecated != "use greet" {
+		t.Errorf("invalid action info: %+v", hello)
+	}
+}
+
+func TestServiceRecovery(t *testing.T) {
+	var (
+		reported interface{}
+		svc      = New(WithPanicReporter(func(req Request, recovered interface{}, stack []byte) {
+			reported = recovered
+		}))
+	)
+
+	svc.Register("panic", func(req Request) error { panic("test panic") })
+
+	if err := svc.Handle(&testRequest{action: "panic", ctx: context.Background()}); err != ErrInternal {
+		t.Errorf("expected error %v, got %v", ErrInternal, err)
+	}
+	if reported != "test panic" {
+		t.Errorf("panic must be reported: %v", reported)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("panic must not be recovered")
+		}
+	}()
+	svc = New(WithRecovery(false))
+	svc.Register("panic", func(req Request) error { panic("test panic") })
+	_ = svc.Handle(&testRequest{action: "panic", ctx: context.Background()})
+}
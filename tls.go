@@ -0,0 +1,204 @@
+//
+// @project geniusrabbit::xrpc 2017
+// @author Dmitry Ponomarev <demdxx@gmail.com> 2017
+//
+
+package xrpc
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var errTLSCertificateRequired = errors.New("TLS certificate and key files are required")
+
+// DefaultCertCheckInterval of the certificate files modification
+var DefaultCertCheckInterval = 10 * time.Second
+
+// TLSOptions of the secure connection
+type TLSOptions struct {
+	// CertFile and KeyFile of the server certificate
+	// or the client certificate for mutual TLS
+	CertFile string
+	KeyFile  string
+
+	// CAFile with PEM certificates to verify the peer. The server requires
+	// and verifies client certificates if it's defined (mutual TLS),
+	// the client verifies the server by system roots if it's empty.
+	CAFile string
+
+	// ServerName verified by the client, host of the address by default
+	ServerName string
+
+	// InsecureSkipVerify disables verification of the server by the client
+	InsecureSkipVerify bool
+}
+
+// TLSOptions of the address query parameters `cert`, `key`, `ca`,
+// `server_name` and `insecure`
+func (a Address) TLSOptions() TLSOptions {
+	insecure, _ := strconv.ParseBool(a.Query.Get("insecure"))
+	return TLSOptions{
+		CertFile:           a.Query.Get("cert"),
+		KeyFile:            a.Query.Get("key"),
+		CAFile:             a.Query.Get("ca"),
+		ServerName:         a.Query.Get("server_name"),
+		InsecureSkipVerify: insecure,
+	}
+}
+
+// ServerConfig with the certificate reloaded on the files modification
+func (o TLSOptions) ServerConfig() (*tls.Config, error) {
+	if o.CertFile == "" || o.KeyFile == "" {
+		return nil, errTLSCertificateRequired
+	}
+	reloader, err := NewCertReloader(o.CertFile, o.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+	if o.CAFile != "" {
+		if config.ClientCAs, err = loadCertPool(o.CAFile); err != nil {
+			return nil, err
+		}
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
+
+// ClientConfig with the client certificate reloaded on the files modification
+func (o TLSOptions) ClientConfig() (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         o.ServerName,
+		InsecureSkipVerify: o.InsecureSkipVerify,
+	}
+	if o.CertFile != "" || o.KeyFile != "" {
+		reloader, err := NewCertReloader(o.CertFile, o.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.GetClientCertificate = reloader.GetClientCertificate
+	}
+	if o.CAFile != "" {
+		var err error
+		if config.RootCAs, err = loadCertPool(o.CAFile); err != nil {
+			return nil, err
+		}
+	}
+	return config, nil
+}
+
+func loadCertPool(filename string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates in [%s]", filename)
+	}
+	return pool, nil
+}
+
+// CertReloader keeps the certificate loaded from files and reloads it
+// after the files modification, so certificates are rotated without restart.
+// The previous certificate is used if the new one can't be loaded.
+type CertReloader struct {
+	certFile string
+	keyFile  string
+
+	// CheckInterval of the files modification, DefaultCertCheckInterval by default
+	CheckInterval time.Duration
+
+	mx        sync.RWMutex
+	cert      *tls.Certificate
+	modTime   time.Time
+	checkTime time.Time
+}
+
+// NewCertReloader of the certificate files
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	if certFile == "" || keyFile == "" {
+		return nil, errTLSCertificateRequired
+	}
+	reloader := &CertReloader{certFile: certFile, keyFile: keyFile, CheckInterval: DefaultCertCheckInterval}
+	if err := reloader.Reload(); err != nil {
+		return nil, err
+	}
+	return reloader, nil
+}
+
+// Reload the certificate from files
+func (r *CertReloader) Reload() error {
+	modTime, err := r.filesModTime()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	r.mx.Lock()
+	r.cert, r.modTime, r.checkTime = &cert, modTime, time.Now()
+	r.mx.Unlock()
+	return nil
+}
+
+// Certificate returns current certificate, files are checked
+// for the modification once per the check interval
+func (r *CertReloader) Certificate() *tls.Certificate {
+	r.mx.RLock()
+	cert, modTime, expired := r.cert, r.modTime, time.Since(r.checkTime) >= r.CheckInterval
+	r.mx.RUnlock()
+	if !expired {
+		return cert
+	}
+
+	r.mx.Lock()
+	r.checkTime = time.Now()
+	r.mx.Unlock()
+
+	if newModTime, err := r.filesModTime(); err == nil && !newModTime.Equal(modTime) {
+		if r.Reload() == nil {
+			r.mx.RLock()
+			cert = r.cert
+			r.mx.RUnlock()
+		}
+	}
+	return cert
+}
+
+// GetCertificate callback of the server TLS configuration
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.Certificate(), nil
+}
+
+// GetClientCertificate callback of the client TLS configuration
+func (r *CertReloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return r.Certificate(), nil
+}
+
+// filesModTime returns the last modification time of files
+func (r *CertReloader) filesModTime() (time.Time, error) {
+	var modTime time.Time
+	for _, filename := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(filename)
+		if err != nil {
+			return modTime, err
+		}
+		if info.ModTime().After(modTime) {
+			modTime = info.ModTime()
+		}
+	}
+	return modTime, nil
+}
@@ -0,0 +1,139 @@
+//
+// @project geniusrabbit::xrpc 2017
+// @author Dmitry Ponomarev <demdxx@gmail.com> 2017
+//
+
+package xrpc
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/geniusrabbit/xrpc/internal/testcert"
+)
+
+// handshake of the client and server configurations, returns the server side peer
+func handshake(serverConfig, clientConfig *tls.Config) (Peer, error) {
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", serverConfig)
+	if err != nil {
+		return Peer{}, err
+	}
+	defer listener.Close()
+
+	go func() {
+		conn, err := tls.Dial("tcp", listener.Addr().String(), clientConfig)
+		if err != nil {
+			return
+		}
+		// Keep the connection until the server finishes the handshake
+		_, _ = conn.Read(make([]byte, 1))
+		_ = conn.Close()
+	}()
+
+	conn, err := listener.Accept()
+	if err != nil {
+		return Peer{}, err
+	}
+	defer conn.Close()
+	if err = conn.(*tls.Conn).Handshake(); err != nil {
+		return Peer{}, err
+	}
+	return PeerOf("test", conn), nil
+}
+
+func TestTLSOptions(t *testing.T) {
+	var (
+		ca                    = testcert.NewCA(t)
+		serverCert, serverKey = ca.Pair(t, "server", x509.ExtKeyUsageServerAuth)
+		clientCert, clientKey = ca.Pair(t, "client", x509.ExtKeyUsageClientAuth)
+	)
+
+	serverConfig, err := TLSOptions{CertFile: serverCert, KeyFile: serverKey}.ServerConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientConfig, err := TLSOptions{CAFile: ca.File, ServerName: "localhost"}.ClientConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	peer, err := handshake(serverConfig, clientConfig)
+	if err != nil || !peer.TLS || peer.Certificate != nil {
+		t.Errorf("invalid TLS handshake: %+v %v", peer, err)
+	}
+
+	mutualConfig, err := TLSOptions{CertFile: serverCert, KeyFile: serverKey, CAFile: ca.File}.ServerConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = handshake(mutualConfig, clientConfig); err == nil {
+		t.Error("client without certificate must be rejected")
+	}
+
+	clientConfig, err = TLSOptions{CertFile: clientCert, KeyFile: clientKey, CAFile: ca.File, ServerName: "localhost"}.ClientConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	peer, err = handshake(mutualConfig, clientConfig)
+	if err != nil || peer.Subject() != "CN=client" {
+		t.Errorf("invalid mutual TLS handshake: %+v %v", peer, err)
+	}
+
+	if _, err = (TLSOptions{}).ServerConfig(); err == nil {
+		t.Error("server configuration without certificate must fail")
+	}
+	if _, err = (TLSOptions{CAFile: clientKey}).ClientConfig(); err == nil {
+		t.Error("CA file without certificates must fail")
+	}
+}
+
+func TestCertReloader(t *testing.T) {
+	var (
+		ca                = testcert.NewCA(t)
+		certFile, keyFile = ca.Pair(t, "server", x509.ExtKeyUsageServerAuth)
+		nextCert, nextKey = ca.Pair(t, "next", x509.ExtKeyUsageServerAuth)
+		reloader, err     = NewCertReloader(certFile, keyFile)
+		subjectOf         = func(cert *tls.Certificate) string {
+			c, _ := x509.ParseCertificate(cert.Certificate[0])
+			return c.Subject.CommonName
+		}
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := subjectOf(reloader.Certificate()); name != "server" {
+		t.Fatalf("invalid certificate: %s", name)
+	}
+
+	for _, files := range [][2]string{{nextCert, certFile}, {nextKey, keyFile}} {
+		data, _ := os.ReadFile(files[0])
+		if err = os.WriteFile(files[1], data, 0600); err != nil {
+			t.Fatal(err)
+		}
+		future := time.Now().Add(time.Minute)
+		_ = os.Chtimes(files[1], future, future)
+	}
+
+	if name := subjectOf(reloader.Certificate()); name != "server" {
+		t.Errorf("certificate must not be reloaded before the check interval: %s", name)
+	}
+	reloader.CheckInterval = 0
+	if name := subjectOf(reloader.Certificate()); name != "next" {
+		t.Errorf("certificate must be reloaded: %s", name)
+	}
+
+	// Broken files keep the previous certificate
+	if err = os.WriteFile(certFile, []byte("broken"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(2 * time.Minute)
+	_ = os.Chtimes(certFile, later, later)
+	if name := subjectOf(reloader.Certificate()); name != "next" {
+		t.Errorf("previous certificate must be used: %s", name)
+	}
+	if err = reloader.Reload(); err == nil {
+		t.Error("reload of broken certificate must fail")
+	}
+}
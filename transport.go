@@ -0,0 +1,158 @@
+//
+// @project geniusrabbit::xrpc 2017
+// @author Dmitry Ponomarev <demdxx@gmail.com> 2017
+//
+
+package xrpc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Transport creates clients and servers of the URL scheme,
+// transport options are passed by the query parameters of the address
+type Transport interface {
+	// Dial creates the client connected to the address
+	Dial(address Address) (Client, error)
+
+	// NewServer of the service configured for the address
+	NewServer(svc Service, address Address) (Server, error)
+}
+
+// DefaultPorter is implemented by transports which have the default port,
+// it's used for TCP addresses without the port like `http://example.com`
+type DefaultPorter interface {
+	DefaultPort() string
+}
+
+var transportRegistry struct {
+	mx         sync.RWMutex
+	transports map[string]Transport
+}
+
+// RegisterTransport of the URL scheme, transports are registered
+// by the import of transport packages
+func RegisterTransport(scheme string, transport Transport) {
+	transportRegistry.mx.Lock()
+	defer transportRegistry.mx.Unlock()
+	if transportRegistry.transports == nil {
+		transportRegistry.transports = map[string]Transport{}
+	}
+	transportRegistry.transports[scheme] = transport
+}
+
+// TransportByScheme returns registered transport or nil
+func TransportByScheme(scheme string) Transport {
+	transportRegistry.mx.RLock()
+	defer transportRegistry.mx.RUnlock()
+	return transportRegistry.transports[scheme]
+}
+
+// Dial the client of the transport by URL like `fastrpc://host:20202?compress=snappy`.
+// The network of the connection is defined after the transport name,
+// like `fastrpc+unix:///var/run/service.sock` or `http+tls+tcp://host:443`.
+// The port could be omitted for transports which implement DefaultPorter.
+func Dial(rawURL string) (Client, error) {
+	transport, address, err := parseTransportURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	return transport.Dial(address)
+}
+
+// ListenAndServe the service by the transport of the URL like `http://0.0.0.0:8080`
+func ListenAndServe(svc Service, rawURL string) error {
+	srv, address, err := newServer(svc, rawURL)
+	if err != nil {
+		return err
+	}
+	return srv.Listen(address)
+}
+
+// ListenAndServeContext the service like ListenAndServe until the context is done,
+// then the server is shutdown gracefully. It returns after in-flight requests
+// are finished or the shutdown timeout is expired.
+func ListenAndServeContext(ctx context.Context, svc Service, rawURL string, shutdownTimeout time.Duration) error {
+	srv, address, err := newServer(svc, rawURL)
+	if err != nil {
+		return err
+	}
+	var (
+		stopped  = make(chan struct{})
+		shutdown = make(chan error, 1)
+	)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-stopped:
+			shutdown <- nil
+			return
+		}
+		sctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		shutdown <- srv.Shutdown(sctx)
+	}()
+	err = srv.Listen(address)
+	close(stopped)
+	if serr := <-shutdown; err == nil {
+		err = serr
+	}
+	return err
+}
+
+// newServer of the transport of the URL and the address to listen
+func newServer(svc Service, rawURL string) (Server, string, error) {
+	transport, address, err := parseTransportURL(rawURL)
+	if err != nil {
+		return nil, "", err
+	}
+	srv, err := transport.NewServer(svc, address)
+	if err != nil {
+		return nil, "", err
+	}
+	return srv, address.String(), nil
+}
+
+// parseTransportURL into the registered transport and the connection address
+func parseTransportURL(rawURL string) (Transport, Address, error) {
+	scheme, rest, found := strings.Cut(rawURL, "://")
+	if !found {
+		return nil, Address{}, fmt.Errorf("transport scheme of [%s] is not defined", rawURL)
+	}
+
+	name, network, _ := strings.Cut(scheme, "+")
+	transport := TransportByScheme(name)
+	if transport == nil {
+		return nil, Address{}, fmt.Errorf("transport [%s] is not registered", name)
+	}
+	if network == "" {
+		network = "tcp"
+		if porter, ok := transport.(DefaultPorter); ok {
+			rest = withDefaultPort(rest, porter.DefaultPort())
+		}
+	}
+
+	address, err := ParseAddress(network + "://" + rest)
+	return transport, address, err
+}
+
+// withDefaultPort adds the port to the host without it, query is kept
+func withDefaultPort(hostport, port string) string {
+	host, query, hasQuery := strings.Cut(hostport, "?")
+	if host = strings.TrimSuffix(host, "/"); host == "" {
+		return hostport
+	}
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return hostport
+	}
+	host = net.JoinHostPort(host, port)
+	if hasQuery {
+		host += "?" + query
+	}
+	return host
+}
@@ -0,0 +1,119 @@
+//
+// @project geniusrabbit::xrpc 2017
+// @author Dmitry Ponomarev <demdxx@gmail.com> 2017
+//
+
+package xrpc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type testTransport struct {
+	address Address
+}
+
+func (t *testTransport) Dial(address Address) (Client, error) {
+	t.address = address
+	return &testClient{}, nil
+}
+
+func (t *testTransport) NewServer(svc Service, address Address) (Server, error) {
+	t.address = address
+	return nil, errors.New("not implemented")
+}
+
+// testServerTransport creates the test server
+type testServerTransport struct {
+	testTransport
+	server *testServer
+}
+
+func (t *testServerTransport) NewServer(svc Service, address Address) (Server, error) {
+	t.address = address
+	return t.server, nil
+}
+
+// testPortTransport with the default port
+type testPortTransport struct {
+	testTransport
+}
+
+func (t *testPortTransport) DefaultPort() string { return "8000" }
+
+func TestTransportRegistry(t *testing.T) {
+	transport := &testTransport{}
+	RegisterTransport("test", transport)
+
+	if _, err := Dial("test://localhost:8080?codec=json"); err != nil {
+		t.Fatal(err)
+	}
+	if transport.address.Network != "tcp" || transport.address.Address != "localhost:8080" {
+		t.Errorf("invalid address: %+v", transport.address)
+	}
+	if codec, err := transport.address.Codec(); err != nil || codec != JSONCodec {
+		t.Errorf("invalid codec option: %v %v", codec, err)
+	}
+
+	if _, err := Dial("test+unix:///var/run/test.sock"); err != nil {
+		t.Fatal(err)
+	}
+	if transport.address.Network != "unix" || transport.address.Address != "/var/run/test.sock" {
+		t.Errorf("invalid unix address: %+v", transport.address)
+	}
+
+	if err := ListenAndServe(New(), "test+tls+tcp://:8443"); err == nil || !transport.address.TLS {
+		t.Errorf("invalid TLS address: %+v %v", transport.address, err)
+	}
+
+	portTransport := &testPortTransport{}
+	RegisterTransport("testport", portTransport)
+	if _, err := Dial("testport://localhost/?codec=json"); err != nil {
+		t.Fatal(err)
+	}
+	if address := portTransport.address; address.Address != "localhost:8000" || address.Query.Get("codec") != "json" {
+		t.Errorf("address must have the default port: %+v", address)
+	}
+	if _, err := Dial("testport://localhost:9000"); err != nil || portTransport.address.Address != "localhost:9000" {
+		t.Errorf("port of the address must be kept: %+v %v", portTransport.address, err)
+	}
+
+	for _, rawURL := range []string{"localhost:8080", "unknown://localhost:8080", "test+udp://localhost:53", "test://localhost", "testport+tcp://localhost"} {
+		if _, err := Dial(rawURL); err == nil {
+			t.Errorf("[%s] error expected", rawURL)
+		}
+	}
+}
+
+func TestListenAndServeContext(t *testing.T) {
+	var (
+		srv       = &testServer{listen: map[string]bool{}, stop: make(chan struct{}), inflight: true}
+		transport = &testServerTransport{server: srv}
+		served    = make(chan error, 1)
+	)
+	RegisterTransport("testserver", transport)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		served <- ListenAndServeContext(ctx, New(), "testserver://localhost:8080", 20*time.Millisecond)
+	}()
+	for srv.listened() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+
+	// The shutdown is waited and the interrupted requests are reported
+	var shutdownErr *ShutdownError
+	if err := <-served; !errors.As(err, &shutdownErr) {
+		t.Errorf("shutdown error expected: %v", err)
+	}
+	if !srv.listen["tcp://localhost:8080"] {
+		t.Errorf("invalid address: %v", srv.listen)
+	}
+	if err := ListenAndServeContext(context.Background(), New(), "unknown://localhost:8080", time.Second); err == nil {
+		t.Error("unknown transport error expected")
+	}
+}